@@ -1,6 +1,7 @@
 package art
 
 import (
+	"context"
 	"reflect"
 	"sync/atomic"
 )
@@ -46,8 +47,10 @@ type Adapter struct {
 
 	rawStop func(Logger) error
 
-	fixupMaxRetrySecond int
-	rawFixup            func(IAdapter) error
+	codecs *CodecRegistry
+
+	fixupPolicy RetryPolicy
+	rawFixup    func(IAdapter) error
 
 	// WaitPingSendPong or SendPingWaitPong
 	pp func() error
@@ -92,10 +95,10 @@ func (adp *Adapter) pingpong() {
 			Err = adp.pp()
 			return
 		}
-		Err = ReliableTask(
+		Err = ReliableTaskWithPolicy(
 			adp.pp,
 			adp.IsStopped,
-			adp.fixupMaxRetrySecond,
+			adp.fixupPolicy,
 			func() error { return adp.rawFixup(adp.application) },
 		)
 	}()
@@ -132,10 +135,10 @@ func (adp *Adapter) Listen() (err error) {
 			Err = adp.listen()
 			return
 		}
-		Err = ReliableTask(
+		Err = ReliableTaskWithPolicy(
 			adp.listen,
 			adp.IsStopped,
-			adp.fixupMaxRetrySecond,
+			adp.fixupPolicy,
 			func() error { return adp.rawFixup(adp.application) },
 		)
 	}()
@@ -155,6 +158,13 @@ func (adp *Adapter) listen() error {
 			return err
 		}
 
+		if adp.codecs != DefaultCodecs {
+			if ingress.Ctx == nil {
+				ingress.Ctx = context.Background()
+			}
+			ingress.Ctx = CtxWithCodecs(ingress.Ctx, adp.codecs)
+		}
+
 		err = adp.ingressMux.HandleMessage(ingress, adp.application)
 		if err != nil {
 
@@ -191,7 +201,12 @@ func (adp *Adapter) RawSend(messages ...*Message) error {
 			return nil
 		}
 
-		err := adp.rawSend(adp.logger, egress)
+		err := adp.codecs.Encode(egress)
+		if err != nil {
+			return err
+		}
+
+		err = adp.rawSend(adp.logger, egress)
 		if err != nil {
 			return err
 		}
@@ -9,6 +9,7 @@ func NewAdapterOption() (opt *AdapterOption) {
 		recvResult: make(chan error, 2),
 		lifecycle:  new(Lifecycle),
 		waitStop:   make(chan struct{}),
+		codecs:     DefaultCodecs,
 	}
 	return &AdapterOption{
 		adapter: pubsub,
@@ -104,6 +105,18 @@ func (opt *AdapterOption) RawSend(send func(logger Logger, message *Message) err
 	return opt
 }
 
+// Codecs replace DefaultCodecs.
+// Adapter.RawSend encodes Message.Body into Message.Bytes by the content-type of Message.Metadata,
+// and the ingress messages carry the registry in Message.Ctx, so HandleOf with nil codec decodes symmetrically.
+func (opt *AdapterOption) Codecs(codecs *CodecRegistry) *AdapterOption {
+	if codecs == nil {
+		return opt
+	}
+	pubsub := opt.adapter
+	pubsub.codecs = codecs
+	return opt
+}
+
 func (opt *AdapterOption) RawStop(stop func(logger Logger) error) *AdapterOption {
 	pubsub := opt.adapter
 	pubsub.rawStop = stop
@@ -118,9 +131,13 @@ func (opt *AdapterOption) RawFixup(maxRetrySecond int, fixup func(IAdapter) erro
 		const RetryUntilAdapterStop = 0
 		maxRetrySecond = RetryUntilAdapterStop
 	}
+	return opt.RawFixupPolicy(DefaultRetryPolicy(maxRetrySecond), fixup)
+}
 
+// RawFixupPolicy is the same as RawFixup, but fixup is retried by policy.
+func (opt *AdapterOption) RawFixupPolicy(policy RetryPolicy, fixup func(IAdapter) error) *AdapterOption {
 	pubsub := opt.adapter
-	pubsub.fixupMaxRetrySecond = maxRetrySecond
+	pubsub.fixupPolicy = policy
 	pubsub.rawFixup = fixup
 	return opt
 }
@@ -0,0 +1,239 @@
+package art
+
+import (
+	"sync"
+	"time"
+)
+
+type CircuitState uint8
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (state CircuitState) String() string {
+	switch state {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// UseCircuitBreaker
+// The circuit is tracked per Message.Subject by default, or per the key of KeyFunc.
+//
+// closed: the handler is executed, after FailureThreshold consecutive failures, the circuit becomes open.
+// open: the handler isn't executed and ErrCircuitOpen is returned, after CoolDown, the circuit becomes half-open.
+// half-open: one message at a time is executed as a probe,
+// after SuccessThreshold consecutive successes, the circuit becomes closed, otherwise it becomes open again.
+//
+// State changes are logged by the Logger of Message.Ctx.
+//
+//	mux.Middleware(art.UseCircuitBreaker{}.FailureThreshold(5).CoolDown(30 * time.Second).Middleware())
+type UseCircuitBreaker struct {
+	failureThreshold int
+	successThreshold int
+	coolDown         time.Duration
+	keyFunc          func(message *Message) string
+	isFailure        func(err error) bool
+}
+
+// FailureThreshold default is 5.
+func (use UseCircuitBreaker) FailureThreshold(n int) UseCircuitBreaker {
+	use.failureThreshold = n
+	return use
+}
+
+// SuccessThreshold is the number of successes in half-open state to close the circuit, default is 1.
+func (use UseCircuitBreaker) SuccessThreshold(n int) UseCircuitBreaker {
+	use.successThreshold = n
+	return use
+}
+
+// CoolDown default is 30 seconds.
+func (use UseCircuitBreaker) CoolDown(d time.Duration) UseCircuitBreaker {
+	use.coolDown = d
+	return use
+}
+
+func (use UseCircuitBreaker) KeyFunc(keyFunc func(message *Message) string) UseCircuitBreaker {
+	use.keyFunc = keyFunc
+	return use
+}
+
+// IsFailure classify the error of handler, default is err != nil.
+func (use UseCircuitBreaker) IsFailure(isFailure func(err error) bool) UseCircuitBreaker {
+	use.isFailure = isFailure
+	return use
+}
+
+func (use UseCircuitBreaker) Middleware() Middleware {
+	if use.failureThreshold <= 0 {
+		use.failureThreshold = 5
+	}
+	if use.successThreshold <= 0 {
+		use.successThreshold = 1
+	}
+	if use.coolDown <= 0 {
+		use.coolDown = 30 * time.Second
+	}
+	if use.keyFunc == nil {
+		use.keyFunc = func(message *Message) string { return message.Subject }
+	}
+	if use.isFailure == nil {
+		use.isFailure = func(err error) bool { return err != nil }
+	}
+
+	var mu sync.Mutex
+	circuits := make(map[string]*circuit)
+	calls := 0
+
+	return func(next HandleFunc) HandleFunc {
+		return func(message *Message, dep any) error {
+			key := use.keyFunc(message)
+
+			mu.Lock()
+			calls++
+			if calls%1024 == 0 {
+				sweepCircuits(circuits)
+			}
+			c, ok := circuits[key]
+			if !ok {
+				c = &circuit{}
+				circuits[key] = c
+			}
+			from, to, allow, probe := c.allow(time.Now(), use.coolDown)
+			if allow {
+				c.running++
+			}
+			mu.Unlock()
+
+			if from != to {
+				logCircuit(message, key, from, to)
+			}
+			if !allow {
+				return ErrorWrapWithMessage(ErrCircuitOpen, "key=%q", key)
+			}
+
+			// if next panics, the call is recorded as a failure
+			failed := true
+			defer func() {
+				mu.Lock()
+				c.running--
+				from, to := c.done(time.Now(), failed, probe, use.failureThreshold, use.successThreshold)
+				mu.Unlock()
+
+				if from != to {
+					logCircuit(message, key, from, to)
+				}
+			}()
+
+			err := next(message, dep)
+			failed = use.isFailure(err)
+			return err
+		}
+	}
+}
+
+// sweepCircuits remove the closed circuits without failures and running calls, they are the same as new circuits.
+func sweepCircuits(circuits map[string]*circuit) {
+	for key, c := range circuits {
+		if c.state == CircuitClosed && c.failures == 0 && c.running == 0 {
+			delete(circuits, key)
+		}
+	}
+}
+
+func logCircuit(message *Message, key string, from, to CircuitState) {
+	logger := DefaultLogger()
+	if message.Ctx != nil {
+		logger = CtxGetLogger(message.Ctx)
+	}
+
+	if to == CircuitOpen {
+		logger.Warn("circuit breaker key=%q: %v -> %v", key, from, to)
+		return
+	}
+	logger.Info("circuit breaker key=%q: %v -> %v", key, from, to)
+}
+
+type circuit struct {
+	state     CircuitState
+	failures  int
+	successes int
+	openedAt  time.Time
+	probing   bool
+	running   int
+}
+
+// allow return whether the call is executed, and whether it is the probe of half-open state.
+func (c *circuit) allow(now time.Time, coolDown time.Duration) (from, to CircuitState, allow, probe bool) {
+	from = c.state
+
+	switch c.state {
+	case CircuitClosed:
+		return from, c.state, true, false
+
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < coolDown {
+			return from, c.state, false, false
+		}
+		c.state = CircuitHalfOpen
+		c.successes = 0
+		c.probing = false
+	}
+
+	if c.probing {
+		return from, c.state, false, false
+	}
+	c.probing = true
+	return from, c.state, true, true
+}
+
+// done record the result of call.
+// In half-open state, only the result of probe is recorded,
+// the calls which started while the circuit was closed are ignored.
+func (c *circuit) done(now time.Time, failed, probe bool, failureThreshold, successThreshold int) (from, to CircuitState) {
+	from = c.state
+
+	switch c.state {
+	case CircuitClosed:
+		if !failed {
+			c.failures = 0
+			break
+		}
+		c.failures++
+		if c.failures >= failureThreshold {
+			c.open(now)
+		}
+
+	case CircuitHalfOpen:
+		if !probe {
+			break
+		}
+		c.probing = false
+		if failed {
+			c.open(now)
+			break
+		}
+		c.successes++
+		if c.successes >= successThreshold {
+			c.state = CircuitClosed
+			c.failures = 0
+		}
+	}
+
+	return from, c.state
+}
+
+func (c *circuit) open(now time.Time) {
+	c.state = CircuitOpen
+	c.openedAt = now
+	c.failures = 0
+}
@@ -0,0 +1,228 @@
+package art
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUseCircuitBreaker(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	ctx := CtxWithLogger(context.Background(), NewWriterLogger(buffer, false, LogLevelDebug))
+
+	fail := true
+	handler := Link(func(message *Message, dep any) error {
+		if fail && message.Subject == "orders" {
+			return errors.New("downstream fail")
+		}
+		return nil
+	}, UseCircuitBreaker{}.
+		FailureThreshold(2).
+		CoolDown(30*time.Millisecond).
+		Middleware())
+
+	recorder := []string{}
+	send := func(subject string) {
+		err := handler(&Message{Subject: subject, Ctx: ctx}, nil)
+		switch {
+		case err == nil:
+			recorder = append(recorder, subject+" ok")
+		case errors.Is(err, ErrCircuitOpen):
+			recorder = append(recorder, subject+" open")
+		default:
+			recorder = append(recorder, subject+" fail")
+		}
+	}
+
+	send("orders")
+	send("orders")
+	send("orders")
+	send("users") // the circuit is tracked per subject
+
+	time.Sleep(40 * time.Millisecond)
+	send("orders") // half-open probe fails
+	send("orders")
+
+	time.Sleep(40 * time.Millisecond)
+	fail = false
+	send("orders") // half-open probe succeeds
+	send("orders")
+
+	expected := []string{
+		"orders fail",
+		"orders fail",
+		"orders open",
+		"users ok",
+		"orders fail",
+		"orders open",
+		"orders ok",
+		"orders ok",
+	}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+
+	expectedLogs := []string{
+		`circuit breaker key="orders": closed -> open`,
+		`circuit breaker key="orders": open -> half-open`,
+		`circuit breaker key="orders": half-open -> open`,
+		`circuit breaker key="orders": open -> half-open`,
+		`circuit breaker key="orders": half-open -> closed`,
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != len(expectedLogs) {
+		t.Fatalf("unexpected logs: got %v", lines)
+	}
+	for i, line := range lines {
+		if !strings.HasSuffix(line, expectedLogs[i]) {
+			t.Errorf("unexpected log: got %v, want %v", line, expectedLogs[i])
+		}
+	}
+}
+
+func TestUseCircuitBreaker_KeyFunc(t *testing.T) {
+	handler := Link(func(message *Message, dep any) error {
+		return errors.New("fail")
+	}, UseCircuitBreaker{}.
+		FailureThreshold(1).
+		KeyFunc(func(message *Message) string { return message.Metadata.Str("host") }).
+		Middleware())
+
+	hosts := []string{"a", "b", "a"}
+	codes := []int{}
+	for i, host := range hosts {
+		err := handler(&Message{Subject: fmt.Sprint(i), Metadata: map[string]any{"host": host}}, nil)
+		codes = append(codes, ErrorExtractCode(err))
+	}
+
+	open := ErrCircuitOpen.MyCode()
+	expected := []int{0, 0, open}
+	if fmt.Sprint(codes) != fmt.Sprint(expected) {
+		t.Errorf("unexpected codes: got %v, want %v", codes, expected)
+	}
+}
+
+func TestUseCircuitBreaker_when_handler_panic(t *testing.T) {
+	panicking := true
+	handler := Link(func(message *Message, dep any) error {
+		if panicking {
+			panic("downstream panic")
+		}
+		return nil
+	}, UseCircuitBreaker{}.
+		FailureThreshold(1).
+		CoolDown(10*time.Millisecond).
+		Middleware())
+
+	send := func() (err error) {
+		defer func() {
+			if recover() != nil {
+				err = errors.New("panic")
+			}
+		}()
+		return handler(&Message{Subject: "orders"}, nil)
+	}
+
+	results := []string{}
+	record := func(err error) {
+		switch {
+		case err == nil:
+			results = append(results, "ok")
+		case errors.Is(err, ErrCircuitOpen):
+			results = append(results, "open")
+		default:
+			results = append(results, err.Error())
+		}
+	}
+
+	record(send())
+	record(send())
+
+	time.Sleep(20 * time.Millisecond)
+	record(send()) // half-open probe panics
+
+	time.Sleep(20 * time.Millisecond)
+	panicking = false
+	record(send())
+
+	expected := []string{"panic", "open", "panic", "ok"}
+	if fmt.Sprint(results) != fmt.Sprint(expected) {
+		t.Errorf("unexpected results: got %v, want %v", results, expected)
+	}
+}
+
+func TestUseCircuitBreaker_when_half_open_with_closed_call(t *testing.T) {
+	started := make(chan struct{})
+	release := map[string]chan struct{}{
+		"slow":  make(chan struct{}),
+		"probe": make(chan struct{}),
+	}
+	handler := Link(func(message *Message, dep any) error {
+		ch, ok := release[message.Subject]
+		if !ok {
+			return errors.New("fail")
+		}
+		started <- struct{}{}
+		<-ch
+		return nil
+	}, UseCircuitBreaker{}.
+		FailureThreshold(1).
+		CoolDown(10*time.Millisecond).
+		KeyFunc(func(message *Message) string { return "orders" }).
+		Middleware())
+
+	errs := make(chan error, 2)
+	go func() { errs <- handler(&Message{Subject: "slow"}, nil) }()
+	<-started
+
+	handler(&Message{Subject: "fail"}, nil)
+	time.Sleep(20 * time.Millisecond)
+
+	go func() { errs <- handler(&Message{Subject: "probe"}, nil) }()
+	<-started
+
+	// the call which started while the circuit was closed doesn't close the circuit
+	close(release["slow"])
+	if err := <-errs; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := handler(&Message{Subject: "fail"}, nil)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("unexpected error: got %v, want %v", err, ErrCircuitOpen)
+	}
+
+	close(release["probe"])
+	if err := <-errs; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = handler(&Message{Subject: "fail"}, nil)
+	if err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected the circuit is closed: got %v", err)
+	}
+}
+
+func Test_sweepCircuits(t *testing.T) {
+	circuits := map[string]*circuit{
+		"idle":    {state: CircuitClosed},
+		"failed":  {state: CircuitClosed, failures: 1},
+		"running": {state: CircuitClosed, running: 1},
+		"open":    {state: CircuitOpen},
+	}
+
+	sweepCircuits(circuits)
+
+	keys := []string{}
+	for _, key := range []string{"idle", "failed", "running", "open"} {
+		if _, ok := circuits[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	expected := []string{"failed", "running", "open"}
+	if fmt.Sprint(keys) != fmt.Sprint(expected) {
+		t.Errorf("unexpected circuits: got %v, want %v", keys, expected)
+	}
+}
@@ -44,8 +44,8 @@ func (f *{{.FileName}}Factory) CreateAdapter() (adapter {{.FileName}}Adapter, er
 		Identifier(name).
 		AdapterHub(f.Hub).
 		Logger(f.Logger).
-		IngressMux(f.IngressMux).
-		EgressMux(f.EgressMux).
+		IngressMux(f.IngressMux.Mux()).
+		EgressMux(f.EgressMux.Mux()).
 		DecorateAdapter(f.DecorateAdapter).
 		Lifecycle(f.Lifecycle)
 
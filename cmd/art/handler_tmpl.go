@@ -7,18 +7,18 @@ import (
 	"github.com/KScaesar/art"
 )
 
-type {{.FileName}}IngressMux = art.Mux
-type {{.FileName}}EgressMux = art.Mux
+type {{.FileName}}IngressMux = art.MuxOf[art.IAdapter]
+type {{.FileName}}EgressMux = art.MuxOf[art.IAdapter]
 
 func New{{.FileName}}IngressMux(pingpong bool) *{{.FileName}}IngressMux {
-	in := art.NewMux("/").
-		Transform(func(message *art.Message, dep any) error {
+	in := art.NewMuxOf[art.IAdapter]("/").
+		Transform(func(message *art.Message, adp art.IAdapter) error {
 			return nil
 		}).
-		Handler("pong", func(message *art.Message, dep any) error {
+		Handler("pong", func(message *art.Message, adp art.IAdapter) error {
 			art.CtxGetPingPong(message.Ctx).Ack()
 			if pingpong {
-				dep.(art.IAdapter).Log().Debug("ack pong")
+				adp.Log().Debug("ack pong")
 			}
 			return nil
 		})
@@ -26,13 +26,13 @@ func New{{.FileName}}IngressMux(pingpong bool) *{{.FileName}}IngressMux {
 }
 
 func New{{.FileName}}EgressMux(pingpong bool) *{{.FileName}}EgressMux {
-	out := art.NewMux("/").
-		Transform(func(message *art.Message, dep any) error {
+	out := art.NewMuxOf[art.IAdapter]("/").
+		Transform(func(message *art.Message, adp art.IAdapter) error {
 			return nil
 		}).
-		Handler("ping", func(message *art.Message, dep any) error {
+		Handler("ping", func(message *art.Message, adp art.IAdapter) error {
 			if pingpong {
-				dep.(art.IAdapter).Log().Debug("send ping")
+				adp.Log().Debug("send ping")
 			}
 			return nil
 		})
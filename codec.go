@@ -0,0 +1,278 @@
+package art
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Codec convert between Message.Body and Message.Bytes.
+type Codec interface {
+	Encode(v any) ([]byte, error)
+	Decode(bytes []byte, v any) error
+}
+
+// CodecJSON use encoding/json
+var CodecJSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Decode(bytes []byte, v any) error {
+	return json.Unmarshal(bytes, v)
+}
+
+// CodecGob use encoding/gob
+var CodecGob Codec = gobCodec{}
+
+type gobCodec struct{}
+
+func (gobCodec) Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Decode(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// CodecXML use encoding/xml
+var CodecXML Codec = xmlCodec{}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Encode(v any) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Decode(bytes []byte, v any) error {
+	return xml.Unmarshal(bytes, v)
+}
+
+// CodecRaw pass the bytes through without conversion.
+// It encodes []byte or string, and decodes into *[]byte or *string.
+var CodecRaw Codec = rawCodec{}
+
+type rawCodec struct{}
+
+func (rawCodec) Encode(v any) ([]byte, error) {
+	switch body := v.(type) {
+	case []byte:
+		return body, nil
+	case string:
+		return []byte(body), nil
+	}
+	return nil, fmt.Errorf("raw codec can't encode %T", v)
+}
+
+func (rawCodec) Decode(data []byte, v any) error {
+	switch body := v.(type) {
+	case *[]byte:
+		*body = append((*body)[:0], data...)
+		return nil
+	case *string:
+		*body = string(data)
+		return nil
+	}
+	return fmt.Errorf("raw codec can't decode into %T", v)
+}
+
+// MetadataContentType is the key of Message.Metadata, which selects the codec from CodecRegistry.
+const MetadataContentType = "content-type"
+
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeGob  = "application/x-gob"
+	ContentTypeXML  = "application/xml"
+	ContentTypeRaw  = "application/octet-stream"
+)
+
+// DefaultCodecs is used by Adapter and HandleOf, when no CodecRegistry is specified.
+var DefaultCodecs = NewCodecRegistry()
+
+// NewCodecRegistry create a CodecRegistry with built-in JSON, gob, XML and raw codecs.
+func NewCodecRegistry() *CodecRegistry {
+	return (&CodecRegistry{codecs: make(map[string]Codec)}).
+		Register(ContentTypeJSON, CodecJSON).
+		Register(ContentTypeGob, CodecGob).
+		Register(ContentTypeXML, CodecXML).
+		Register("text/xml", CodecXML).
+		Register(ContentTypeRaw, CodecRaw)
+}
+
+// CodecRegistry select the Codec by the content-type of Message.Metadata.
+type CodecRegistry struct {
+	mu     sync.RWMutex
+	codecs map[string]Codec
+}
+
+// Register add or replace the codec of contentType.
+func (r *CodecRegistry) Register(contentType string, codec Codec) *CodecRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codecs[mediaType(contentType)] = codec
+	return r
+}
+
+// Lookup find the codec of contentType, parameters such as "; charset=utf-8" are ignored.
+func (r *CodecRegistry) Lookup(contentType string) (Codec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	codec, ok := r.codecs[mediaType(contentType)]
+	return codec, ok
+}
+
+// CodecOf find the codec by the content-type of message.
+// If the content-type is absent, return false without error.
+func (r *CodecRegistry) CodecOf(message *Message) (codec Codec, ok bool, err error) {
+	contentType := ContentType(message)
+	if contentType == "" {
+		return nil, false, nil
+	}
+	codec, ok = r.Lookup(contentType)
+	if !ok {
+		return nil, false, fmt.Errorf("content-type=%q: %w", contentType, ErrNotFoundCodec)
+	}
+	return codec, true, nil
+}
+
+// Encode convert Message.Body into Message.Bytes by the content-type of message.
+// It does nothing when Message.Bytes has been set, Message.Body is nil, or the content-type is absent.
+func (r *CodecRegistry) Encode(message *Message) error {
+	if message.Body == nil || len(message.Bytes) != 0 {
+		return nil
+	}
+	codec, ok, err := r.CodecOf(message)
+	if !ok {
+		return err
+	}
+	message.Bytes, err = codec.Encode(message.Body)
+	if err != nil {
+		return fmt.Errorf("subject=%q: encode %T: %w", message.Subject, message.Body, err)
+	}
+	return nil
+}
+
+func mediaType(contentType string) string {
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = contentType[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// ContentType get the content-type from Message.Metadata.
+func ContentType(message *Message) string {
+	contentType, _ := message.Metadata.Get(MetadataContentType).(string)
+	return contentType
+}
+
+// SetContentType set the content-type into Message.Metadata.
+func SetContentType(message *Message, contentType string) {
+	message.Metadata.Set(MetadataContentType, contentType)
+}
+
+var codecsKey = "codecs"
+
+func CtxWithCodecs(ctx context.Context, v *CodecRegistry) context.Context {
+	return context.WithValue(ctx, &codecsKey, v)
+}
+
+func CtxGetCodecs(ctx context.Context) *CodecRegistry {
+	codecs, ok := ctx.Value(&codecsKey).(*CodecRegistry)
+	if !ok {
+		return DefaultCodecs
+	}
+	return codecs
+}
+
+// HandleOf create a HandleFunc with the typed body.
+//
+// If Message.Body is T, it is used directly.
+// Otherwise, Message.Bytes is decoded into T by codec, and the result is stored in Message.Body.
+// If codec is nil, it is selected by the content-type of Message.Metadata from CtxGetCodecs.
+// If the body can't be got, return DecodeError.
+//
+//	mux.Handler("orders/created", art.HandleOf(art.CodecJSON, func(msg *art.Message, ev OrderCreated, dep any) error {
+//		...
+//	}))
+func HandleOf[T any](codec Codec, fn func(msg *Message, body T, dep any) error) HandleFunc {
+	return func(message *Message, dep any) error {
+		body, err := decodeBody[T](codec, message)
+		if err != nil {
+			return err
+		}
+		return fn(message, body, dep)
+	}
+}
+
+func decodeBody[T any](codec Codec, message *Message) (body T, err error) {
+	if v, ok := message.Body.(T); ok {
+		return v, nil
+	}
+
+	if message.Body != nil {
+		return body, newDecodeError[T](message, fmt.Errorf("body type is %T", message.Body))
+	}
+	if len(message.Bytes) == 0 {
+		return body, newDecodeError[T](message, errors.New("message has no body"))
+	}
+	if codec == nil {
+		var ok bool
+		codecs := DefaultCodecs
+		if message.Ctx != nil {
+			codecs = CtxGetCodecs(message.Ctx)
+		}
+		codec, ok, err = codecs.CodecOf(message)
+		if err != nil {
+			return body, newDecodeError[T](message, err)
+		}
+		if !ok {
+			return body, newDecodeError[T](message, errors.New("codec is nil and content-type is absent"))
+		}
+	}
+
+	err = codec.Decode(message.Bytes, &body)
+	if err != nil {
+		return body, newDecodeError[T](message, err)
+	}
+	message.Body = body
+	return body, nil
+}
+
+// DecodeError is returned by HandleOf, when Message.Bytes can't be decoded into the typed body.
+// It can be distinguished by errors.As or errors.Is(err, ErrDecodeBody).
+type DecodeError struct {
+	Subject  string
+	BodyType reflect.Type
+	Err      error
+}
+
+func newDecodeError[T any](message *Message, err error) *DecodeError {
+	return &DecodeError{
+		Subject:  message.Subject,
+		BodyType: reflect.TypeOf((*T)(nil)).Elem(),
+		Err:      err,
+	}
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("subject=%q: decode %v: %v: %v", e.Subject, e.BodyType, e.Err, ErrDecodeBody)
+}
+
+func (e *DecodeError) Unwrap() []error {
+	return []error{ErrDecodeBody, e.Err}
+}
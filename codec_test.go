@@ -0,0 +1,207 @@
+package art
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHandleOf(t *testing.T) {
+	recorder := []string{}
+	handler := HandleOf(CodecJSON, func(msg *Message, body testOrderCreated, dep any) error {
+		recorder = append(recorder, fmt.Sprintf("%v %T", body.Id, msg.Body))
+		return nil
+	})
+	ptrHandler := HandleOf(CodecJSON, func(msg *Message, body *testOrderCreated, dep any) error {
+		recorder = append(recorder, fmt.Sprintf("%v %T", body.Id, msg.Body))
+		return nil
+	})
+
+	tests := []struct {
+		handler HandleFunc
+		message *Message
+	}{
+		{handler: handler, message: &Message{Bytes: []byte(`{"Id":"1"}`)}},
+		{handler: handler, message: &Message{Body: testOrderCreated{Id: "2"}}},
+		{handler: ptrHandler, message: &Message{Bytes: []byte(`{"Id":"3"}`)}},
+		{handler: ptrHandler, message: &Message{Body: &testOrderCreated{Id: "4"}}},
+	}
+
+	for _, tt := range tests {
+		err := tt.handler(tt.message, nil)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	expected := []string{
+		"1 art.testOrderCreated",
+		"2 art.testOrderCreated",
+		"3 *art.testOrderCreated",
+		"4 *art.testOrderCreated",
+	}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+}
+
+func TestHandleOf_when_decode_fail(t *testing.T) {
+	handler := HandleOf(CodecJSON, func(msg *Message, body testOrderCreated, dep any) error {
+		t.Errorf("unexpected call")
+		return nil
+	})
+
+	messages := []*Message{
+		{Subject: "invalid json", Bytes: []byte(`{"Id":`)},
+		{Subject: "wrong body", Body: testOrderCanceled{}},
+		{Subject: "empty"},
+	}
+
+	for _, message := range messages {
+		err := handler(message, nil)
+
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) {
+			t.Errorf("%v: expected DecodeError: got %v", message.Subject, err)
+			continue
+		}
+		if decodeErr.Subject != message.Subject {
+			t.Errorf("unexpected subject: got %v, want %v", decodeErr.Subject, message.Subject)
+		}
+		if ErrorExtractCode(err) != ErrDecodeBody.MyCode() {
+			t.Errorf("%v: unexpected code: got %v", message.Subject, ErrorExtractCode(err))
+		}
+	}
+}
+
+func TestHandleOf_when_mux_error_handler(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	logger := NewWriterLogger(buffer, false, LogLevelDebug)
+
+	recorder := []string{}
+	mux := NewMux("/").
+		ErrorHandler(func(next HandleFunc) HandleFunc {
+			return func(message *Message, dep any) error {
+				err := next(message, dep)
+				if errors.Is(err, ErrDecodeBody) {
+					recorder = append(recorder, "decode error "+message.Subject)
+					return nil
+				}
+				return err
+			}
+		}).
+		Middleware(UsePrintResult{}.PrintIngress().PostMiddleware()).
+		Handler("orders", HandleOf(CodecJSON, func(msg *Message, body testOrderCreated, dep any) error {
+			return nil
+		}))
+
+	message := &Message{
+		Subject: "orders",
+		Bytes:   []byte(`{`),
+		Ctx:     CtxWithLogger(context.Background(), logger),
+	}
+	err := mux.HandleMessage(message, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := []string{"decode error orders"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+	if !strings.Contains(buffer.String(), `decode "orders" fail`) {
+		t.Errorf("unexpected log: %v", buffer.String())
+	}
+}
+
+func TestCodecRegistry(t *testing.T) {
+	recorder := []string{}
+	handler := HandleOf(nil, func(msg *Message, body testOrderCreated, dep any) error {
+		recorder = append(recorder, body.Id)
+		return nil
+	})
+	rawHandler := HandleOf(nil, func(msg *Message, body string, dep any) error {
+		recorder = append(recorder, body)
+		return nil
+	})
+
+	tests := []struct {
+		contentType string
+		body        any
+		handler     HandleFunc
+	}{
+		{contentType: ContentTypeJSON, body: testOrderCreated{Id: "json"}, handler: handler},
+		{contentType: "application/json; charset=utf-8", body: testOrderCreated{Id: "json charset"}, handler: handler},
+		{contentType: ContentTypeGob, body: testOrderCreated{Id: "gob"}, handler: handler},
+		{contentType: ContentTypeXML, body: testOrderCreated{Id: "xml"}, handler: handler},
+		{contentType: ContentTypeRaw, body: "raw", handler: rawHandler},
+	}
+
+	for _, tt := range tests {
+		egress := &Message{Body: tt.body, Metadata: map[string]any{}}
+		SetContentType(egress, tt.contentType)
+		err := DefaultCodecs.Encode(egress)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tt.contentType, err)
+			continue
+		}
+
+		ingress := &Message{Bytes: egress.Bytes, Metadata: egress.Metadata, Ctx: context.Background()}
+		err = tt.handler(ingress, nil)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tt.contentType, err)
+		}
+	}
+
+	expected := []string{"json", "json charset", "gob", "xml", "raw"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+
+	unknown := &Message{Body: "text", Metadata: map[string]any{MetadataContentType: "text/csv"}}
+	err := DefaultCodecs.Encode(unknown)
+	if !errors.Is(err, ErrNotFoundCodec) {
+		t.Errorf("expected ErrNotFoundCodec: got %v", err)
+	}
+}
+
+func TestAdapter_RawSend_when_codecs(t *testing.T) {
+	recorder := []string{}
+	codecs := NewCodecRegistry().Register("text/plain", CodecRaw)
+
+	egressMux := NewMux("/").
+		DefaultHandler(func(message *Message, dep any) error {
+			return dep.(Producer).RawSend(message)
+		})
+
+	adp, err := NewAdapterOption().
+		Codecs(codecs).
+		EgressMux(egressMux).
+		RawSend(func(logger Logger, message *Message) error {
+			recorder = append(recorder, string(message.Bytes))
+			return nil
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	messages := []*Message{
+		{Subject: "json", Body: testOrderCreated{Id: "1"}, Metadata: map[string]any{MetadataContentType: ContentTypeJSON}},
+		{Subject: "text", Body: "hello", Metadata: map[string]any{MetadataContentType: "text/plain"}},
+		{Subject: "bytes", Body: "ignored", Bytes: []byte("preset"), Metadata: map[string]any{MetadataContentType: ContentTypeJSON}},
+		{Subject: "absent", Body: "ignored", Metadata: map[string]any{}},
+	}
+	err = adp.(Producer).Send(messages...)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := []string{`{"Id":"1"}`, "hello", "preset", ""}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %q, want %q", recorder, expected)
+	}
+}
@@ -0,0 +1,63 @@
+package art
+
+// Compile produce a CompiledMux from the current routes of mux.
+// The lazy middleware is resolved before compiling.
+//
+// Registering or removing handlers after compiling won't affect the CompiledMux,
+// call Compile again to get the new routes.
+func (mux *Mux) Compile() *CompiledMux {
+	if mux.shared.lazyMiddleware {
+		mux.shared.resolveMiddleware()
+	}
+
+	mux.shared.RLock()
+	defer mux.shared.RUnlock()
+
+	return &CompiledMux{
+		root:              compileRadix(mux.node),
+		errorHandlers:     mux.errorHandlers,
+		enableMessagePool: mux.enableMessagePool,
+		routes:            mux.node.routes(),
+	}
+}
+
+// CompiledMux is an immutable Mux for high-throughput dispatch.
+//
+// The matching semantics are the same as Mux, includes Transform, DefaultHandler and NotFoundHandler,
+// but the routes are stored in a path-compressed tree, and no lock is required when handling messages.
+type CompiledMux struct {
+	root              *radixNode
+	errorHandlers     []Middleware
+	enableMessagePool bool
+	routes            Routes
+}
+
+// HandleMessage is also a HandleFunc, but with added routing capabilities.
+func (mux *CompiledMux) HandleMessage(message *Message, dependency any) (err error) {
+	if mux.enableMessagePool {
+		defer PutMessage(message)
+	}
+
+	if mux.errorHandlers != nil {
+		defer func() {
+			err = handleError(err, mux.errorHandlers)(message, dependency)
+		}()
+	}
+
+	search := trieSearchPool.Get()
+	search.reset()
+	defer trieSearchPool.Put(search)
+
+	handler, params, err := mux.root.findHandler(message.Subject, search, message, dependency)
+	if err != nil {
+		return err
+	}
+
+	message.routeParams.append(params)
+	return handler(message, dependency)
+}
+
+// Routes get register handler structured information at the time of compiling.
+func (mux *CompiledMux) Routes() Routes {
+	return mux.routes
+}
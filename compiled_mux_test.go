@@ -0,0 +1,166 @@
+package art
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMux_Compile(t *testing.T) {
+	var recorder []string
+	record := func(name string) HandleFunc {
+		return func(message *Message, dep any) error {
+			params := message.Params()
+			keys := make([]string, 0, len(params))
+			for key, value := range params {
+				keys = append(keys, fmt.Sprintf("%v=%v", key, value))
+			}
+			sort.Strings(keys)
+			recorder = append(recorder, fmt.Sprintf("%v %v", name, strings.Join(keys, ",")))
+			return nil
+		}
+	}
+
+	tests := []struct {
+		name     string
+		setup    func() *Mux
+		subjects []string
+	}{
+		{
+			name: "brace syntax",
+			setup: func() *Mux {
+				mux := NewMux("/")
+				mux.Middleware(UseExclude([]string{"users/list"}))
+				mux.
+					Handler("users/{id}/orders", record("orders")).
+					Handler("users/{name}/profile", record("profile")).
+					Handler("users/list", record("list")).
+					Handler("{a}/x/{b}/end", record("end")).
+					Handler("{c}/x/y/other", record("other")).
+					Handler("p/{rest...}", record("rest")).
+					Handler("p/{name}", record("name")).
+					Handler("p/{id:int}", record("int")).
+					Handler("p/static", record("static")).
+					NotFoundHandler(record("notFound"))
+				mux.Group("g/{id}/").
+					DefaultHandler(record("default")).
+					Handler("detail", record("detail"))
+				mux.Group("t/").
+					Transform(func(message *Message, dep any) error {
+						message.Subject = "t/" + strings.ToUpper(message.Subject[2:])
+						return nil
+					}).
+					Handler("HELLO", record("transform"))
+				return mux
+			},
+			subjects: []string{
+				"users/1017/orders", "users/caesar/profile", "users/list", "users/lis", "users/list/",
+				"k/x/y/end", "k/x/y/other", "p/static", "p/12", "p/ab", "p/ab/cd", "p/",
+				"g/99/detail", "g/99/unknown", "g/99", "t/hello", "t/bye", "unknown", "",
+			},
+		},
+		{
+			name: "mqtt syntax",
+			setup: func() *Mux {
+				mux := NewMQTTMux()
+				mux.
+					Handler("sensors/+/temp", record("temp")).
+					Handler("sport/tennis/#", record("tennis")).
+					Handler("+/status", record("status")).
+					Handler("#", record("all"))
+				return mux
+			},
+			subjects: []string{
+				"sensors/kitchen/temp", "sport/tennis", "sport/tennis/player1/score", "sport/tennis/",
+				"a/status", "$SYS/status", "$SYS/x", "sport",
+			},
+		},
+		{
+			name: "nats syntax",
+			setup: func() *Mux {
+				mux := NewNATSMux()
+				mux.
+					Handler("orders.*.created", record("created")).
+					Handler("orders.>", record("orders")).
+					DefaultHandler(record("default"))
+				return mux
+			},
+			subjects: []string{
+				"orders.tw.created", "orders..created", "orders.tw.created.v1", "orders", "orders.", "foo",
+			},
+		},
+		{
+			name: "without delimiter",
+			setup: func() *Mux {
+				mux := NewMux("")
+				mux.
+					Handler("hello", record("hello")).
+					Handler("help", record("help")).
+					HandlerByNumber(1, record("number"))
+				return mux
+			},
+			subjects: []string{
+				"hello", "help", "hel", "helps", "1", "2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			mux := tt.setup()
+			compiled := mux.Compile()
+
+			for _, subject := range tt.subjects {
+				recorder = nil
+				errMux := mux.HandleMessage(&Message{Subject: subject, RouteParam: map[string]any{}}, nil)
+				errCompiled := compiled.HandleMessage(&Message{Subject: subject, RouteParam: map[string]any{}}, nil)
+
+				if fmt.Sprint(errMux) != fmt.Sprint(errCompiled) {
+					t.Errorf("%q: unexpected error: got %v, want %v", subject, errCompiled, errMux)
+				}
+				if len(recorder) == 2 && recorder[0] != recorder[1] {
+					t.Errorf("%q: unexpected output: got %s, want %s", subject, recorder[1], recorder[0])
+				}
+				if len(recorder) == 1 {
+					t.Errorf("%q: unexpected output: %v", subject, recorder)
+				}
+			}
+		})
+	}
+}
+
+func TestCompiledMux_when_mux_changed(t *testing.T) {
+	var recorder []string
+	record := func(name string) HandleFunc {
+		return func(message *Message, dep any) error {
+			recorder = append(recorder, name)
+			return nil
+		}
+	}
+
+	mux := NewMux("/").EnableLazyMiddleware()
+	mux.Handler("hello", record("hello"))
+	mux.PreMiddleware(record("mw"))
+
+	compiled := mux.Compile()
+	mux.Handler("bye", record("bye"))
+
+	err := compiled.HandleMessage(&Message{Subject: "hello", RouteParam: map[string]any{}}, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = compiled.HandleMessage(&Message{Subject: "bye", RouteParam: map[string]any{}}, nil)
+	if err != ErrNotFoundSubject {
+		t.Errorf("unexpected error: got %v, want %v", err, ErrNotFoundSubject)
+	}
+
+	expected := []string{"mw", "hello"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+	if len(compiled.Routes()) != 1 {
+		t.Errorf("unexpected routes: %v", compiled.Routes())
+	}
+}
@@ -0,0 +1,120 @@
+package art
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// DeadLetterSubject is the subject of dead letters forwarded by UseDeadLetter.
+const DeadLetterSubject = "dead-letter"
+
+// The keys of Message.Metadata recorded by UseDeadLetter.
+const (
+	MetadataDeadLetterReason   = "dead-letter-reason"
+	MetadataDeadLetterAttempts = "dead-letter-attempts"
+	MetadataDeadLetterSubject  = "dead-letter-subject"
+)
+
+// UseDeadLetter is the same as UseDeadLetterTo, with DeadLetterSubject.
+func UseDeadLetter(producer Producer) Middleware {
+	return UseDeadLetterTo(producer, DeadLetterSubject)
+}
+
+// UseDeadLetterTo
+// When the handler finally fails, a copy of the message is sent to the subject by Producer.Send,
+// with the failure reason, attempt count and original subject recorded in Message.Metadata.
+// If UseRetry or UseRetryPolicy is inside UseDeadLetterTo, the attempt count includes every retry.
+//
+// If the dead letter is sent, the error is logged and nil is returned, so the message won't be redelivered.
+// Otherwise, return both the handler error and the send error.
+//
+// The copy isn't returned to the message pool by UseDeadLetterTo,
+// because the egress mux of Producer returns it if EnableMessagePool is used.
+//
+//	mux.Middleware(art.UseDeadLetter(producer), art.UseRetryPolicy(policy))
+func UseDeadLetterTo(producer Producer, subject string) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		return func(message *Message, dep any) error {
+			original := message.Ctx
+			parent := original
+			if parent == nil {
+				parent = context.Background()
+			}
+			attempts := new(atomic.Int64)
+			message.Ctx = context.WithValue(parent, &attemptsKey, attempts)
+
+			err := next(message, dep)
+			message.Ctx = original
+			if err == nil {
+				return nil
+			}
+
+			attempt := int(attempts.Load())
+			if attempt == 0 {
+				attempt = 1
+			}
+
+			deadLetter := message.Copy()
+			deadLetter.Subject = subject
+			deadLetter.Metadata.Set(MetadataDeadLetterReason, err.Error())
+			deadLetter.Metadata.Set(MetadataDeadLetterAttempts, attempt)
+			deadLetter.Metadata.Set(MetadataDeadLetterSubject, message.Subject)
+
+			Err := producer.Send(deadLetter)
+			if Err != nil {
+				return errors.Join(err, Err)
+			}
+
+			CtxGetLogger(parent).Warn("subject=%q: forward to dead letter %q after %v attempts: %v", message.Subject, subject, attempt, err)
+			return nil
+		}
+	}
+}
+
+var attemptsKey = "attempts"
+
+// countAttempt is used by UseRetryPolicy, the count is reported by UseDeadLetter.
+func countAttempt(ctx context.Context) {
+	if ctx == nil {
+		return
+	}
+	attempts, ok := ctx.Value(&attemptsKey).(*atomic.Int64)
+	if ok {
+		attempts.Add(1)
+	}
+}
+
+// RedriveDeadLetter create a HandleFunc which re-drives dead letters back through target.
+// The original subject is restored, and the metadata recorded by UseDeadLetter is removed.
+// If the message isn't a dead letter, return ErrNotFoundSubject.
+//
+//	dlqMux.Handler(art.DeadLetterSubject, art.RedriveDeadLetter(ingressMux))
+func RedriveDeadLetter(target *Mux) HandleFunc {
+	return func(message *Message, dep any) error {
+		subject, ok := message.Metadata.Get(MetadataDeadLetterSubject).(string)
+		if !ok {
+			return ErrorWrapWithMessage(ErrNotFoundSubject, "subject=%q: not a dead letter", message.Subject)
+		}
+
+		target.shared.RLock()
+		enableMessagePool := target.enableMessagePool
+		target.shared.RUnlock()
+
+		redrive := message.Copy()
+		if !enableMessagePool {
+			defer PutMessage(redrive)
+		}
+
+		redrive.Subject = subject
+		for key := range redrive.RouteParam {
+			delete(redrive.RouteParam, key)
+		}
+		redrive.routeParams.reset()
+		delete(redrive.Metadata, MetadataDeadLetterReason)
+		delete(redrive.Metadata, MetadataDeadLetterAttempts)
+		delete(redrive.Metadata, MetadataDeadLetterSubject)
+
+		return target.HandleMessage(redrive, dep)
+	}
+}
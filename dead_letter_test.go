@@ -0,0 +1,104 @@
+package art
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestUseDeadLetter(t *testing.T) {
+	deadLetters := []*Message{}
+	producer, err := NewAdapterOption().
+		EgressMux(NewMux("/").DefaultHandler(func(message *Message, dep any) error {
+			deadLetters = append(deadLetters, message.Copy())
+			return nil
+		})).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	recorder := []string{}
+	fail := true
+	mux := NewMux("/").
+		Middleware(
+			UseDeadLetter(producer.(Producer)),
+			UseRetryPolicy(ConstantRetry(time.Millisecond).MaxAttempts(3)),
+		).
+		Handler("orders/{id}", func(message *Message, dep any) error {
+			if fail {
+				return errors.New("downstream fail")
+			}
+			recorder = append(recorder, "order "+message.ParamString("id"))
+			return nil
+		})
+
+	message := &Message{Subject: "orders/1017", RouteParam: map[string]any{}, Metadata: map[string]any{}}
+	err = mux.HandleMessage(message, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if message.Ctx != nil {
+		t.Errorf("expected nil Message.Ctx is restored: got %v", message.Ctx)
+	}
+	if len(deadLetters) != 1 {
+		t.Fatalf("expected dead letter: got %v", len(deadLetters))
+	}
+
+	deadLetter := deadLetters[0]
+	actual := fmt.Sprint(
+		deadLetter.Subject,
+		deadLetter.Metadata.Get(MetadataDeadLetterSubject),
+		deadLetter.Metadata.Get(MetadataDeadLetterAttempts),
+		deadLetter.Metadata.Get(MetadataDeadLetterReason),
+	)
+	expected := fmt.Sprint("dead-letter", "orders/1017", 3, "downstream fail")
+	if actual != expected {
+		t.Errorf("unexpected dead letter: got %v, want %v", actual, expected)
+	}
+
+	fail = false
+	dlqMux := NewMux("/").
+		Handler(DeadLetterSubject, RedriveDeadLetter(mux))
+
+	err = dlqMux.HandleMessage(deadLetter, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expectedRecorder := []string{"order 1017"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expectedRecorder) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expectedRecorder)
+	}
+}
+
+func TestUseDeadLetter_when_send_fail(t *testing.T) {
+	errSend := errors.New("send fail")
+	errHandler := errors.New("handler fail")
+
+	producer, err := NewAdapterOption().
+		EgressMux(NewMux("/").DefaultHandler(func(message *Message, dep any) error {
+			return errSend
+		})).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	handler := Link(func(message *Message, dep any) error {
+		return errHandler
+	}, UseDeadLetter(producer.(Producer)))
+
+	err = handler(GetMessage(), nil)
+	if !errors.Is(err, errHandler) || !errors.Is(err, errSend) {
+		t.Errorf("expected both errors: got %v", err)
+	}
+}
+
+func TestRedriveDeadLetter_when_not_dead_letter(t *testing.T) {
+	err := RedriveDeadLetter(NewMux("/"))(GetMessage(), nil)
+	if !errors.Is(err, ErrNotFoundSubject) {
+		t.Errorf("expected ErrNotFoundSubject: got %v", err)
+	}
+}
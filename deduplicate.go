@@ -0,0 +1,219 @@
+package art
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+type DedupState uint8
+
+const (
+	DedupNew        DedupState = iota // the key is reserved by the caller
+	DedupInProgress                   // the key is being processed
+	DedupProcessed                    // the key has been processed successfully
+)
+
+// DedupStore records the keys of messages for UseDeduplicate.
+// It can be implemented by a shared storage, e.g. Redis SET NX, to deduplicate across processes.
+type DedupStore interface {
+	// Reserve mark the key as in progress, and return DedupNew.
+	// If the key already exists, return its state without change.
+	Reserve(key string) (DedupState, error)
+
+	// Commit mark the key as processed successfully.
+	Commit(key string) error
+
+	// Release remove the key, so the message can be processed again.
+	Release(key string) error
+}
+
+// NewMemoryDedupStore create an in-memory DedupStore,
+// the keys are evicted after ttl since they are reserved or committed.
+func NewMemoryDedupStore(ttl time.Duration) *MemoryDedupStore {
+	return &MemoryDedupStore{
+		ttl:     ttl,
+		entries: make(map[string]dedupEntry),
+	}
+}
+
+type MemoryDedupStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]dedupEntry
+	calls   int
+}
+
+type dedupEntry struct {
+	state    DedupState
+	expireAt time.Time
+}
+
+func (store *MemoryDedupStore) Reserve(key string) (DedupState, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	store.calls++
+	if store.calls%1024 == 0 {
+		store.evict(now)
+	}
+
+	entry, ok := store.entries[key]
+	if ok && now.Before(entry.expireAt) {
+		return entry.state, nil
+	}
+
+	store.entries[key] = dedupEntry{state: DedupInProgress, expireAt: now.Add(store.ttl)}
+	return DedupNew, nil
+}
+
+func (store *MemoryDedupStore) Commit(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.entries[key] = dedupEntry{state: DedupProcessed, expireAt: time.Now().Add(store.ttl)}
+	return nil
+}
+
+func (store *MemoryDedupStore) Release(key string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.entries, key)
+	return nil
+}
+
+// Len return the number of keys which aren't evicted yet.
+func (store *MemoryDedupStore) Len() int {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.evict(time.Now())
+	return len(store.entries)
+}
+
+func (store *MemoryDedupStore) evict(now time.Time) {
+	for key, entry := range store.entries {
+		if !now.Before(entry.expireAt) {
+			delete(store.entries, key)
+		}
+	}
+}
+
+// UseDeduplicate
+// The message which has been processed successfully is skipped on redelivery,
+// the key is Message.MsgId by default, or the key of KeyFunc.
+//
+// Duplicates which arrive while the message is in progress in the same middleware wait for it, and get the same result.
+// If the message is in progress in another process which shares the DedupStore, return ErrDuplicateInProgress,
+// so the broker can redeliver it later.
+// If the handler fails, the key is released, and the message can be processed again.
+//
+//	mux.Middleware(art.UseDeduplicate{}.Store(art.NewMemoryDedupStore(time.Hour)).Middleware())
+type UseDeduplicate struct {
+	store   DedupStore
+	keyFunc func(message *Message) string
+}
+
+// Store default is NewMemoryDedupStore(10 * time.Minute).
+func (use UseDeduplicate) Store(store DedupStore) UseDeduplicate {
+	use.store = store
+	return use
+}
+
+func (use UseDeduplicate) KeyFunc(keyFunc func(message *Message) string) UseDeduplicate {
+	use.keyFunc = keyFunc
+	return use
+}
+
+func (use UseDeduplicate) Middleware() Middleware {
+	if use.store == nil {
+		use.store = NewMemoryDedupStore(10 * time.Minute)
+	}
+	if use.keyFunc == nil {
+		use.keyFunc = func(message *Message) string { return message.MsgId() }
+	}
+
+	var mu sync.Mutex
+	calls := make(map[string]*dedupCall)
+
+	return func(next HandleFunc) HandleFunc {
+		return func(message *Message, dep any) error {
+			key := use.keyFunc(message)
+
+			mu.Lock()
+			if call, ok := calls[key]; ok {
+				mu.Unlock()
+				return call.wait(message)
+			}
+			call := &dedupCall{done: make(chan struct{}), err: errors.New("deduplicate: handler panic")}
+			calls[key] = call
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				delete(calls, key)
+				mu.Unlock()
+				close(call.done)
+			}()
+
+			call.err = use.process(key, next, message, dep)
+			return call.err
+		}
+	}
+}
+
+func (use UseDeduplicate) process(key string, next HandleFunc, message *Message, dep any) error {
+	state, err := use.store.Reserve(key)
+	if err != nil {
+		return err
+	}
+
+	switch state {
+	case DedupProcessed:
+		return nil
+	case DedupInProgress:
+		return ErrorWrapWithMessage(ErrDuplicateInProgress, "key=%q", key)
+	}
+
+	processed := false
+	defer func() {
+		if processed {
+			return
+		}
+		if Err := use.store.Release(key); Err != nil {
+			logger := DefaultLogger()
+			if message.Ctx != nil {
+				logger = CtxGetLogger(message.Ctx)
+			}
+			logger.Error("deduplicate release key=%q: %v", key, Err)
+		}
+	}()
+
+	err = next(message, dep)
+	if err != nil {
+		return err
+	}
+	processed = true
+	return use.store.Commit(key)
+}
+
+type dedupCall struct {
+	done chan struct{}
+	err  error
+}
+
+func (call *dedupCall) wait(message *Message) error {
+	if message.Ctx == nil {
+		<-call.done
+		return call.err
+	}
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-message.Ctx.Done():
+		return message.Ctx.Err()
+	}
+}
@@ -0,0 +1,113 @@
+package art
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUseDeduplicate(t *testing.T) {
+	recorder := []string{}
+	fail := true
+
+	handler := Link(func(message *Message, dep any) error {
+		recorder = append(recorder, message.MsgId())
+		if fail && message.MsgId() == "2" {
+			return errors.New("fail")
+		}
+		return nil
+	}, UseDeduplicate{}.Middleware())
+
+	send := func(msgId string) error {
+		message := &Message{}
+		message.SetMsgId(msgId)
+		return handler(message, nil)
+	}
+
+	send("1")
+	send("1") // skipped on redelivery
+	send("2") // failed
+	fail = false
+	send("2") // processed again after failure
+	send("2")
+
+	expected := []string{"1", "2", "2"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+}
+
+func TestUseDeduplicate_when_in_progress(t *testing.T) {
+	var processed atomic.Int32
+	release := make(chan struct{})
+	errHandler := errors.New("handler fail")
+
+	handler := Link(func(message *Message, dep any) error {
+		processed.Add(1)
+		<-release
+		return errHandler
+	}, UseDeduplicate{}.KeyFunc(func(message *Message) string { return message.Subject }).Middleware())
+
+	const duplicates = 5
+	errs := make(chan error, duplicates)
+	var wg sync.WaitGroup
+	for i := 0; i < duplicates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- handler(&Message{Subject: "order"}, nil)
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	if processed.Load() != 1 {
+		t.Errorf("expected in-progress duplicates are coalesced: processed %v", processed.Load())
+	}
+	for err := range errs {
+		if !errors.Is(err, errHandler) {
+			t.Errorf("expected the same result: got %v", err)
+		}
+	}
+}
+
+func TestUseDeduplicate_when_store_shared(t *testing.T) {
+	store := NewMemoryDedupStore(time.Minute)
+	store.Reserve("order") // reserved by another process
+
+	handler := Link(func(message *Message, dep any) error {
+		t.Errorf("unexpected call")
+		return nil
+	}, UseDeduplicate{}.Store(store).KeyFunc(func(message *Message) string { return message.Subject }).Middleware())
+
+	err := handler(&Message{Subject: "order"}, nil)
+	if !errors.Is(err, ErrDuplicateInProgress) {
+		t.Errorf("expected ErrDuplicateInProgress: got %v", err)
+	}
+}
+
+func TestMemoryDedupStore_when_ttl(t *testing.T) {
+	store := NewMemoryDedupStore(20 * time.Millisecond)
+	store.Reserve("1")
+	store.Commit("1")
+
+	state, _ := store.Reserve("1")
+	if state != DedupProcessed {
+		t.Errorf("unexpected state: got %v", state)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if store.Len() != 0 {
+		t.Errorf("expected the key is evicted: len %v", store.Len())
+	}
+	state, _ = store.Reserve("1")
+	if state != DedupNew {
+		t.Errorf("unexpected state: got %v", state)
+	}
+}
@@ -3,12 +3,32 @@ package art
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
-	ErrClosed          = NewCustomError(2001, "service has been closed")
-	ErrNotFound        = NewCustomError(2100, "not found")
-	ErrNotFoundSubject = NewCustomError(2101, "not found subject mux")
+	ErrClosed           = NewCustomError(2001, "service has been closed")
+	ErrNotFound         = NewCustomError(2100, "not found")
+	ErrNotFoundSubject  = NewCustomError(2101, "not found subject mux")
+	ErrNotFoundBodyType = NewCustomError(2102, "not found body type")
+	ErrNotFoundCodec    = NewCustomError(2103, "not found codec")
+
+	ErrInvalidRouteParam = NewCustomError(2200, "invalid route param")
+
+	ErrInvalidRoute    = NewCustomError(2300, "invalid route")
+	ErrDuplicatedRoute = NewCustomError(2301, "duplicated route")
+
+	ErrDecodeBody = NewCustomError(2400, "decode body fail")
+
+	ErrInvalidDependency = NewCustomError(2500, "invalid dependency type")
+
+	ErrTimeout = NewCustomError(2600, "handler timeout")
+
+	ErrCircuitOpen = NewCustomError(2700, "circuit breaker is open")
+
+	ErrRateLimited = NewCustomError(2800, "rate limited")
+
+	ErrDuplicateInProgress = NewCustomError(2900, "duplicate message in progress")
 )
 
 //
@@ -53,3 +73,46 @@ func (c *CustomError) MyCode() int {
 }
 
 func (c *CustomError) CustomError() {}
+
+// RouteError describe why the subject can't be registered into Mux,
+// Kind is ErrInvalidRoute or ErrDuplicatedRoute.
+type RouteError struct {
+	Subject string // full subject template, includes the prefix of groups
+	Reason  string
+	Kind    error
+}
+
+func newRouteError(kind error, subject string, reason string, args ...any) *RouteError {
+	return &RouteError{
+		Subject: subject,
+		Reason:  fmt.Sprintf(reason, args...),
+		Kind:    kind,
+	}
+}
+
+func (e *RouteError) Error() string {
+	return fmt.Sprintf("subject=%q: %v: %v", e.Subject, e.Reason, e.Kind)
+}
+
+func (e *RouteError) Unwrap() error {
+	return e.Kind
+}
+
+// RouteErrors is returned by Mux.Validate, it can be inspected by errors.Is and errors.As.
+type RouteErrors []*RouteError
+
+func (errs RouteErrors) Error() string {
+	msg := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msg = append(msg, err.Error())
+	}
+	return strings.Join(msg, "\n")
+}
+
+func (errs RouteErrors) Unwrap() []error {
+	result := make([]error, 0, len(errs))
+	for _, err := range errs {
+		result = append(result, err)
+	}
+	return result
+}
@@ -18,7 +18,7 @@ func main() {
 
 	// Note:
 	// Before registering handler, middleware must be defined;
-	// otherwise, the handler won't be able to use middleware.
+	// otherwise, it will panic, unless Mux.EnableLazyMiddleware is used.
 	mux.Middleware(
 		art.UseRecover(),
 		art.UsePrintDetail().
@@ -169,13 +169,13 @@ func HandleAuth() art.HandleFunc {
 
 func Hello(message *art.Message, dep any) error {
 	art.CtxGetLogger(message.Ctx).
-		Info("Hello: body=%v user=%v\n", string(message.Bytes), message.RouteParam.Get("user"))
+		Info("Hello: body=%v user=%v\n", string(message.Bytes), message.ParamString("user"))
 	return nil
 }
 
 func UpdatedProductPrice(db map[string]any) art.HandleFunc {
 	return func(message *art.Message, dep any) error {
-		brand := message.RouteParam.Str("brand")
+		brand := message.Params().Str("brand")
 		db[brand] = message.Bytes
 
 		art.CtxGetLogger(message.Ctx).
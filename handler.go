@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"runtime/debug"
+	"sync/atomic"
 	"time"
 )
 
@@ -64,15 +65,22 @@ func UseSkipMessage() func(message *Message, dep any) error {
 }
 
 func UseRetry(retryMaxSecond int) Middleware {
+	return UseRetryPolicy(DefaultRetryPolicy(retryMaxSecond))
+}
+
+// UseRetryPolicy retry the handler by policy,
+// the waiting between attempts is interrupted when Message.Ctx is done.
+func UseRetryPolicy(policy RetryPolicy) Middleware {
 	return func(next HandleFunc) HandleFunc {
 		return func(message *Message, dep any) error {
 			task := func() error {
+				countAttempt(message.Ctx)
 				return next(message, dep)
 			}
-			taskCanStop := func() bool {
-				return false
+			if message.Ctx == nil {
+				return policy.Retry(task, nil)
 			}
-			return ReliableTask(task, taskCanStop, retryMaxSecond, nil)
+			return policy.RetryContext(message.Ctx, task)
 		}
 	}
 }
@@ -122,12 +130,16 @@ func UseRecover() Middleware {
 	}
 }
 
+// UseAsync
+// If UseAsync is inside UseTimeout, the async work keeps the deadline, and it is cancelled on expiry.
 func UseAsync() Middleware {
 	return func(next HandleFunc) HandleFunc {
 		return func(message *Message, dep any) error {
 			message = message.Copy()
+			release := detachTimeout(message.Ctx)
 			go func() {
 				next(message, dep)
+				release()
 				PutMessage(message)
 			}()
 			return nil
@@ -135,6 +147,138 @@ func UseAsync() Middleware {
 	}
 }
 
+// UseTimeout
+// Derive a deadline on Message.Ctx by Message.UpdateContext, and the handler is executed on a copy of message in another goroutine.
+// If the handler doesn't finish before the deadline, return TimeoutError without waiting for it,
+// and the handler should watch Message.Ctx.Done() to stop the remaining work.
+// If the handler finishes in time, the Bytes, Body and Metadata of the copy are written back to message.
+//
+// The copy is returned to the message pool after the handler finishes, so the message can be reused safely by Mux.EnableMessagePool.
+// If the handler panics in time, the panic is propagated to the caller, otherwise it is logged.
+// If UseTimeout is inside UseRetry, every retry gets a fresh deadline.
+// If UseAsync is inside UseTimeout, the async work keeps the deadline, and it is cancelled on expiry.
+func UseTimeout(timeout time.Duration) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		return func(message *Message, dep any) error {
+			parent := message.Ctx
+			if parent == nil {
+				parent = context.Background()
+			}
+
+			ctx, cancel := context.WithTimeout(parent, timeout)
+			state := &timeoutState{cancel: cancel}
+			copied := message.Copy()
+			copied.UpdateContext(func(_ context.Context) context.Context {
+				return context.WithValue(ctx, &timeoutKey, state)
+			})
+
+			done := make(chan timeoutResult, 1)
+			go func() {
+				result := timeoutResult{}
+				defer func() {
+					if r := recover(); r != nil {
+						result.recovered = r
+						result.stack = debug.Stack()
+					}
+					done <- result
+				}()
+				result.err = next(copied, dep)
+			}()
+
+			select {
+			case result := <-done:
+				if !state.detached.Load() {
+					cancel()
+				}
+				expired := errors.Is(ctx.Err(), context.DeadlineExceeded)
+
+				message.Bytes = copied.Bytes
+				message.Body = copied.Body
+				for key := range message.Metadata {
+					delete(message.Metadata, key)
+				}
+				for key, v := range copied.Metadata {
+					message.Metadata.Set(key, v)
+				}
+				PutMessage(copied)
+
+				if result.recovered != nil {
+					panic(result.recovered)
+				}
+				if expired {
+					return &TimeoutError{Subject: message.Subject, Timeout: timeout, Err: result.err}
+				}
+				return result.err
+
+			case <-ctx.Done():
+				cancel()
+				go func() {
+					result := <-done
+					if result.recovered != nil {
+						CtxGetLogger(parent).Error("subject=%q: recovered from panic after %v: %v\n%s", copied.Subject, timeout, result.recovered, result.stack)
+					}
+					PutMessage(copied)
+				}()
+
+				if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return ctx.Err()
+				}
+				return &TimeoutError{Subject: message.Subject, Timeout: timeout}
+			}
+		}
+	}
+}
+
+type timeoutResult struct {
+	err       error
+	recovered any
+	stack     []byte
+}
+
+var timeoutKey = "timeout"
+
+type timeoutState struct {
+	cancel   context.CancelFunc
+	detached atomic.Bool
+}
+
+// detachTimeout transfer the cancel of UseTimeout to the caller,
+// so the deadline is still valid after the handler returns.
+func detachTimeout(ctx context.Context) (release func()) {
+	if ctx == nil {
+		return func() {}
+	}
+	state, ok := ctx.Value(&timeoutKey).(*timeoutState)
+	if !ok {
+		return func() {}
+	}
+	state.detached.Store(true)
+	return state.cancel
+}
+
+// TimeoutError is returned by UseTimeout, when the handler doesn't finish before the deadline.
+// Err is the error returned by the handler, it may be nil.
+// It can be distinguished by errors.As, errors.Is(err, ErrTimeout) or errors.Is(err, context.DeadlineExceeded).
+type TimeoutError struct {
+	Subject string
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("subject=%q: exceed %v: %v", e.Subject, e.Timeout, ErrTimeout)
+	}
+	return fmt.Sprintf("subject=%q: exceed %v: %v: %v", e.Subject, e.Timeout, e.Err, ErrTimeout)
+}
+
+func (e *TimeoutError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrTimeout, context.DeadlineExceeded}
+	}
+	return []error{ErrTimeout, context.DeadlineExceeded, e.Err}
+}
+
 func UseCopyMessage() Middleware {
 	return func(next HandleFunc) HandleFunc {
 		return func(message *Message, dep any) error {
@@ -282,6 +426,12 @@ func (use UsePrintResult) PostMiddleware() Middleware {
 					return err
 				}
 
+				var decodeErr *DecodeError
+				if errors.As(err, &decodeErr) && (use.printIngress || use.printEgress) {
+					logger.Error("decode %q fail: %v", subject, err)
+					return err
+				}
+
 				if use.printIngress {
 					logger.Error("handle %q fail: %v", subject, err)
 					return err
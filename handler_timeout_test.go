@@ -0,0 +1,173 @@
+package art
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUseTimeout(t *testing.T) {
+	cancelled := make(chan error, 1)
+	slow := Link(func(message *Message, dep any) error {
+		ctx := message.Ctx
+		select {
+		case <-ctx.Done():
+			cancelled <- ctx.Err()
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+		return nil
+	}, UseTimeout(20*time.Millisecond))
+
+	err := slow(&Message{Subject: "slow", Ctx: context.Background()}, nil)
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Subject != "slow" {
+		t.Errorf("expected TimeoutError: got %v", err)
+	}
+	if !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error: got %v", err)
+	}
+	if ErrorExtractCode(err) != ErrTimeout.MyCode() {
+		t.Errorf("unexpected code: got %v", ErrorExtractCode(err))
+	}
+	if ctxErr := <-cancelled; !errors.Is(ctxErr, context.DeadlineExceeded) {
+		t.Errorf("expected handler ctx is cancelled: got %v", ctxErr)
+	}
+
+	parent := context.Background()
+	message := &Message{Subject: "fast", Ctx: parent}
+	fast := Link(func(message *Message, dep any) error {
+		if _, ok := message.Ctx.Deadline(); !ok {
+			t.Errorf("expected deadline")
+		}
+		return nil
+	}, UseTimeout(time.Second))
+
+	err = fast(message, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if message.Ctx != parent {
+		t.Errorf("expected Message.Ctx is restored")
+	}
+
+	message = &Message{Subject: "fast"}
+	err = fast(message, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if message.Ctx != nil {
+		t.Errorf("expected nil Message.Ctx is restored: got %v", message.Ctx)
+	}
+}
+
+func TestUseTimeout_when_handler_ignores_ctx(t *testing.T) {
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	handler := Link(func(message *Message, dep any) error {
+		<-release
+		message.Body = "late"
+		close(finished)
+		return nil
+	}, UseTimeout(20*time.Millisecond))
+
+	message := GetMessage()
+	message.Subject = "stuck"
+
+	start := time.Now()
+	err := handler(message, nil)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected handler is bounded by timeout: elapsed %v", elapsed)
+	}
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout: got %v", err)
+	}
+
+	// the message can be reused, the handler works on a copy
+	PutMessage(message)
+	close(release)
+	<-finished
+}
+
+func TestUseTimeout_when_handler_finishes(t *testing.T) {
+	handler := Link(func(message *Message, dep any) error {
+		message.Body = "decoded"
+		message.Metadata.Set("trace", "1")
+		return nil
+	}, UseTimeout(time.Second))
+
+	message := GetMessage()
+	err := handler(message, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if message.Body != "decoded" || message.Metadata.Str("trace") != "1" {
+		t.Errorf("expected the updates of handler are written back: got %v %v", message.Body, message.Metadata)
+	}
+
+	panicking := Link(func(message *Message, dep any) error {
+		panic("boom")
+	}, UseRecover(), UseTimeout(time.Second))
+
+	err = panicking(GetMessage(), nil)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected panic is propagated: got %v", err)
+	}
+}
+
+func TestUseTimeout_when_retry(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	var attempts atomic.Int32
+
+	handler := Link(func(message *Message, dep any) error {
+		attempt := attempts.Add(1)
+		deadline, _ := message.Ctx.Deadline()
+		if remain := time.Until(deadline); remain < timeout/2 {
+			t.Errorf("attempt %v: expected fresh deadline: remain %v", attempt, remain)
+		}
+		if attempt == 1 {
+			<-message.Ctx.Done()
+			return message.Ctx.Err()
+		}
+		return nil
+	}, UseTimeout(timeout))
+
+	message := &Message{Subject: "retry", Ctx: context.Background()}
+
+	// UseRetry calls the handler again with the same message
+	err := handler(message, nil)
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected ErrTimeout: got %v", err)
+	}
+	err = handler(message, nil)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUseTimeout_when_async(t *testing.T) {
+	cancelled := make(chan error, 1)
+	handler := Link(func(message *Message, dep any) error {
+		<-message.Ctx.Done()
+		cancelled <- message.Ctx.Err()
+		return nil
+	}, UseTimeout(20*time.Millisecond), UseAsync())
+
+	err := handler(GetMessage(), nil)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	select {
+	case ctxErr := <-cancelled:
+		if !errors.Is(ctxErr, context.DeadlineExceeded) {
+			t.Errorf("expected async work is cancelled by deadline: got %v", ctxErr)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected async work is cancelled on expiry")
+	}
+}
@@ -2,6 +2,8 @@ package art
 
 import (
 	"context"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/gookit/goutil/maputil"
@@ -46,7 +48,21 @@ type Message struct {
 	//
 	//	get route param:
 	//		key : value => id : 1017
-	RouteParam maputil.Data
+	//
+	// A catch-all wildcard captures the remainder of subject.
+	//
+	//	define mux subject = "/files/{path...}"
+	//	send or recv subject = "/files/a/b/c"
+	//
+	//	get route param:
+	//		key : value => path : a/b/c
+	//
+	// Mux doesn't fill RouteParam, so it doesn't allocate per message,
+	// the captured params are kept in a pooled slice-backed store, and they are got by
+	// Message.ParamString and Message.ParamInt without allocation, or Message.Param and Message.Params.
+	// RouteParam can be set by user, e.g. Transform, it is used if the key isn't captured by Mux.
+	RouteParam  maputil.Data
+	routeParams routeParamStore
 
 	Metadata maputil.Data
 
@@ -73,6 +89,88 @@ func (msg *Message) SetMsgId(msgId string) {
 	msg.identifier = msgId
 }
 
+// Param get the route param captured by Mux,
+// if the key isn't captured, it is got from RouteParam.
+func (msg *Message) Param(key string) (value any, ok bool) {
+	param, ok := msg.routeParams.find(key)
+	if !ok {
+		value, ok = msg.RouteParam[key]
+		return value, ok
+	}
+	if param.value == nil {
+		return param.segment, true
+	}
+	return param.value, true
+}
+
+// ParamString get the route param as string without allocation.
+// The typed value of constrained wildcard is formatted, e.g. uuid.
+//
+// The returned string is valid until the message is returned by PutMessage,
+// use strings.Clone to keep it longer.
+func (msg *Message) ParamString(key string) string {
+	param, ok := msg.routeParams.find(key)
+	if !ok {
+		value, ok := msg.RouteParam[key]
+		if !ok {
+			return ""
+		}
+		return formatRouteParam(value)
+	}
+	if param.value == nil {
+		return param.segment
+	}
+	return formatRouteParam(param.value)
+}
+
+// ParamInt get the route param as int without allocation,
+// if the param isn't found or isn't an integer, return ErrInvalidRouteParam.
+func (msg *Message) ParamInt(key string) (int, error) {
+	param, ok := msg.routeParams.find(key)
+	if ok && param.value == nil {
+		n, err := strconv.Atoi(param.segment)
+		if err != nil {
+			return 0, ErrorWrapWithMessage(ErrInvalidRouteParam, "param %q=%q is not int", key, param.segment)
+		}
+		return n, nil
+	}
+
+	value, ok := msg.Param(key)
+	if !ok {
+		return 0, ErrorWrapWithMessage(ErrInvalidRouteParam, "param %q is missing", key)
+	}
+
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, ErrorWrapWithMessage(ErrInvalidRouteParam, "param %q=%q is not int", key, v)
+		}
+		return n, nil
+	default:
+		return 0, ErrorWrapWithMessage(ErrInvalidRouteParam, "param %q=%v is not int", key, v)
+	}
+}
+
+// Params return the route params captured by Mux and RouteParam as a new map.
+// It allocates, and the values are copied, so they are still valid after the message is reused.
+func (msg *Message) Params() maputil.Data {
+	params := make(maputil.Data, len(msg.RouteParam)+len(msg.routeParams.params))
+	for key, v := range msg.RouteParam {
+		params[key] = v
+	}
+	for _, param := range msg.routeParams.params {
+		if param.value != nil {
+			params[param.key] = param.value
+			continue
+		}
+		params[param.key] = strings.Clone(param.segment)
+	}
+	return params
+}
+
 func (msg *Message) reset() {
 	msg.Subject = ""
 	msg.Bytes = nil
@@ -82,6 +180,7 @@ func (msg *Message) reset() {
 	for key := range msg.RouteParam {
 		delete(msg.RouteParam, key)
 	}
+	msg.routeParams.reset()
 	for key := range msg.Metadata {
 		delete(msg.Metadata, key)
 	}
@@ -101,6 +200,7 @@ func (msg *Message) Copy() *Message {
 	for key, v := range msg.RouteParam {
 		message.RouteParam.Set(key, v)
 	}
+	message.routeParams.append(msg.routeParams.params)
 	for key, v := range msg.Metadata {
 		message.Metadata.Set(key, v)
 	}
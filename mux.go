@@ -1,11 +1,14 @@
 package art
 
 import (
+	"errors"
+	"sort"
 	"strconv"
+	"sync"
 )
 
 // NewMux
-// If routeDelimiter is an empty string, Message.RouteParam cannot be used.
+// If routeDelimiter is an empty string, route params cannot be captured.
 // RouteDelimiter can only be set to a string of length 1.
 // This parameter determines different parts of the Message.Subject.
 func NewMux(routeDelimiter string) *Mux {
@@ -16,69 +19,278 @@ func NewMux(routeDelimiter string) *Mux {
 	var mux = &Mux{
 		node:           newTrie(routeDelimiter),
 		routeDelimiter: routeDelimiter,
+		shared: &muxShared{
+			routeNames: make(map[string]string),
+		},
 	}
+	mux.shared.root = mux.node
+	return mux
+}
+
+// NewMQTTMux
+// The subject is defined by mqtt topic filter,
+// '+' matches a single level, and '#' matches any number of levels, including the parent level.
+// Route delimiter is '/'.
+//
+// The key of route param is the index of wildcard.
+//
+//	define mux subject = "sensors/+/temp/#"
+//	send or recv subject = "sensors/kitchen/temp/c/max"
+//
+//	get route param:
+//		key : value => 0 : kitchen
+//		key : value => 1 : c/max
+func NewMQTTMux() *Mux {
+	mux := NewMux("/")
+	mux.node.syntax = mqttSyntax
+	return mux
+}
+
+// NewNATSMux
+// The subject is defined by nats subject-based wildcards,
+// '*' matches a single token, and '>' matches one or more tokens.
+// Route delimiter is '.'.
+//
+// The key of route param is the index of wildcard.
+//
+//	define mux subject = "orders.*.>"
+//	send or recv subject = "orders.tw.created.v1"
+//
+//	get route param:
+//		key : value => 0 : tw
+//		key : value => 1 : created.v1
+func NewNATSMux() *Mux {
+	mux := NewMux(".")
+	mux.node.syntax = natsSyntax
 	return mux
 }
 
 // Mux refers to a router or multiplexer, which can be used to handle different message.
 //
 // Message represents a high-level abstraction data structure containing metadata (e.g. header) + body
+//
+// Mux is safe for concurrent use, handlers can be registered or removed while messages are being handled.
+// The read lock is held while searching the route, but it is released while the callbacks of user are executed,
+// including Transform, route predicates of When, handlers and error handlers,
+// so they can use the same mux, e.g. BuildSubject, Routes, HandleMessage or registering handlers.
+// Middlewares are linked while the write lock is held,
+// so a Middleware must only use the mux inside the returned HandleFunc, not when it is applied to next.
 type Mux struct {
 	node              *trie
 	routeDelimiter    string
 	errorHandlers     []Middleware
 	enableMessagePool bool
+
+	shared *muxShared
+}
+
+// muxShared is shared by the mux and its groups
+type muxShared struct {
+	sync.RWMutex
+	root *trie
+
+	lazyMiddleware bool
+	unresolved     bool
+
+	routeNames map[string]string // key : value => route name : subject template
+
+	collectRouteErrors bool
+	routeErrors        RouteErrors
+}
+
+// handleRouteError panic, unless Mux.CollectRouteErrors is used.
+func (shared *muxShared) handleRouteError(err error) {
+	if err == nil {
+		return
+	}
+	if !shared.collectRouteErrors {
+		panic(err)
+	}
+
+	var routeErr *RouteError
+	if !errors.As(err, &routeErr) {
+		routeErr = newRouteError(ErrInvalidRoute, "", "%v", err)
+	}
+	shared.routeErrors = append(shared.routeErrors, routeErr)
+}
+
+// unlockAfterRegister
+// When lazy middleware is enabled, the middleware chain will be resolved again before the next message is handled.
+func (shared *muxShared) unlockAfterRegister() {
+	shared.unresolved = shared.lazyMiddleware
+	shared.Unlock()
+}
+
+func (shared *muxShared) resolveMiddleware() {
+	shared.Lock()
+	if shared.unresolved {
+		shared.root.resolveMiddleware(nil)
+		shared.unresolved = false
+	}
+	shared.Unlock()
 }
 
 // HandleMessage is also a HandleFunc, but with added routing capabilities.
 func (mux *Mux) HandleMessage(message *Message, dependency any) (err error) {
-	if mux.enableMessagePool {
+	mux.shared.RLock()
+	errorHandlers := mux.errorHandlers
+	enableMessagePool := mux.enableMessagePool
+	unresolved := mux.shared.unresolved
+	mux.shared.RUnlock()
+
+	if unresolved {
+		mux.shared.resolveMiddleware()
+	}
+
+	if enableMessagePool {
 		defer PutMessage(message)
 	}
 
 	defer func() {
-		if mux.errorHandlers != nil {
-			err = Link(func(message *Message, dep any) error {
-				return err
-			}, mux.errorHandlers...)(message, dependency)
+		if errorHandlers != nil {
+			err = handleError(err, errorHandlers)(message, dependency)
 		}
 	}()
 
-	if mux.node.transform != nil {
-		return mux.node.handleMessage("", 0, message, dependency)
+	search := trieSearchPool.Get()
+	search.reset()
+	defer trieSearchPool.Put(search)
+
+	search.shared = mux.shared
+	mux.shared.RLock()
+	handler, params, err := mux.node.findHandler(message.Subject, 0, search, message, dependency)
+	mux.shared.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	message.routeParams.append(params)
+	return handler(message, dependency)
+}
+
+// HandleNumber is the fast path of HandleMessage for the routes registered by HandlerByNumber and GroupByNumber,
+// the handler is found by integer lookup instead of searching the subject.
+// Numbers are the path of groups and handler, and message.Subject is set to the concrete subject.
+//
+//	mux.GroupByNumber(3).HandlerByNumber(5, h)
+//
+//	mux.HandleNumber(message, dep, 3, 5)
+//	it is the same as: message.Subject = "3/5/"; mux.HandleMessage(message, dep)
+//
+// If the handler isn't registered by number, or there is a Transform on the path,
+// it falls back to HandleMessage, so DefaultHandler and NotFoundHandler still work.
+func (mux *Mux) HandleNumber(message *Message, dependency any, numbers ...int) (err error) {
+	mux.shared.RLock()
+	errorHandlers := mux.errorHandlers
+	enableMessagePool := mux.enableMessagePool
+	unresolved := mux.shared.unresolved
+	handler, subject, ok := mux.node.findNumberHandler(numbers)
+	mux.shared.RUnlock()
+
+	if !ok || unresolved {
+		message.Subject = mux.numberSubject(numbers)
+		return mux.HandleMessage(message, dependency)
+	}
+
+	if enableMessagePool {
+		defer PutMessage(message)
 	}
 
-	return mux.node.handleMessage(message.Subject, 0, message, dependency)
+	defer func() {
+		if errorHandlers != nil {
+			err = handleError(err, errorHandlers)(message, dependency)
+		}
+	}()
+
+	message.Subject = subject
+	return handler(message, dependency)
+}
+
+func (mux *Mux) numberSubject(numbers []int) string {
+	buf := make([]byte, 0, 8*len(numbers))
+	for _, number := range numbers {
+		buf = strconv.AppendInt(buf, int64(number), 10)
+		buf = append(buf, mux.routeDelimiter...)
+	}
+	return string(buf)
 }
 
 // Middleware
-// Before registering handler, middleware must be defined; otherwise, the handler won't be able to use middleware.
+// Before registering handler and group, middleware must be defined; otherwise, it will panic.
+// If EnableLazyMiddleware is used, the registration order doesn't matter.
 func (mux *Mux) Middleware(middlewares ...Middleware) *Mux {
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+	mux.checkMiddlewareOrder()
+
 	param := &paramHandler{
 		middlewares: middlewares,
 	}
 
-	mux.node.addRoute("", 0, param, []Middleware{})
+	_, err := mux.node.addRoute("", 0, param, []Middleware{})
+	mux.shared.handleRouteError(err)
 	return mux
 }
 
 func (mux *Mux) PreMiddleware(handleFuncs ...HandleFunc) *Mux {
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+	mux.checkMiddlewareOrder()
+
 	param := &paramHandler{}
 	for _, h := range handleFuncs {
 		param.middlewares = append(param.middlewares, h.PreMiddleware())
 	}
 
-	mux.node.addRoute("", 0, param, []Middleware{})
+	_, err := mux.node.addRoute("", 0, param, []Middleware{})
+	mux.shared.handleRouteError(err)
 	return mux
 }
 
 func (mux *Mux) PostMiddleware(handleFuncs ...HandleFunc) *Mux {
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+	mux.checkMiddlewareOrder()
+
 	param := &paramHandler{}
 	for _, h := range handleFuncs {
 		param.middlewares = append(param.middlewares, h.PostMiddleware())
 	}
 
-	mux.node.addRoute("", 0, param, []Middleware{})
+	_, err := mux.node.addRoute("", 0, param, []Middleware{})
+	mux.shared.handleRouteError(err)
+	return mux
+}
+
+func (mux *Mux) checkMiddlewareOrder() {
+	if mux.shared.lazyMiddleware {
+		return
+	}
+
+	if mux.node.hasHandlerOrSubGroup() {
+		err := newRouteError(ErrInvalidRoute, mux.node.fullSubject, "middleware must be registered before handler and group, or use Mux.EnableLazyMiddleware")
+		mux.shared.handleRouteError(err)
+	}
+}
+
+// EnableLazyMiddleware
+// The middleware chain is resolved when the next message is handled, instead of when the handler is registered.
+// So middleware added to a Mux or Group applies to all handlers under it, regardless of registration order.
+//
+// It must be enabled on the root mux before registering anything; otherwise, it will panic.
+func (mux *Mux) EnableLazyMiddleware() *Mux {
+	mux.shared.Lock()
+	defer mux.shared.Unlock()
+
+	if mux.node != mux.shared.root {
+		panic("EnableLazyMiddleware must be called by the root mux")
+	}
+	if mux.node.middlewares != nil || mux.node.hasHandlerOrSubGroup() {
+		panic("EnableLazyMiddleware must be called before registering middleware, handler and group")
+	}
+
+	mux.shared.lazyMiddleware = true
+	mux.node.lazy = true
 	return mux
 }
 
@@ -87,43 +299,230 @@ func (mux *Mux) PostMiddleware(handleFuncs ...HandleFunc) *Mux {
 // However, if there is a definition of Transform,
 // when the message passes through the Transform function, 'getSubject' will be called again.
 func (mux *Mux) Transform(transform HandleFunc) *Mux {
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+
 	param := &paramHandler{
 		transform: transform,
 	}
 
-	mux.node.addRoute("", 0, param, []Middleware{})
+	_, err := mux.node.addRoute("", 0, param, []Middleware{})
+	mux.shared.handleRouteError(err)
 	return mux
 }
 
+// Handler
+// Subject can contain wildcards to capture route params, e.g. "users/{id}".
+// A catch-all wildcard "{name...}" captures the remainder of the subject, and must be at the end.
+// A constrained wildcard "{name:constraint}" only matches the segment which satisfies the constraint,
+// constraint is a regexp or a type (int, uuid, ulid) whose converted value will be got by Message.Param.
+//
+// Wildcards with different names can be defined at the same position, e.g. "users/{id}/orders" and "users/{name}/profile".
+// Matching priority is static > constrained > plain > catch-all,
+// and if a branch can't match the whole subject, it will backtrack to try the next branch.
+//
+// Route predicates created by When or WhenMeta can be passed with middlewares,
+// the handler is selected only if predicates are matched, otherwise the plain handler of the same subject is used.
+//
+//	mux.Handler("orders", OrdersV2, art.WhenMeta("version", "2")).
+//		Handler("orders", Orders)
 func (mux *Mux) Handler(subject string, h HandleFunc, mw ...Middleware) *Mux {
+	return mux.NamedHandler("", subject, h, mw...)
+}
+
+// NamedHandler is the same as Handler, but the route can be found by name in BuildSubject.
+// Route name must be unique in the mux and its groups.
+func (mux *Mux) NamedHandler(name string, subject string, h HandleFunc, mw ...Middleware) *Mux {
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+
+	err := mux.registerHandler(name, subject, h, "", mw...)
+	mux.shared.handleRouteError(err)
+	return mux
+}
+
+// TryHandler is the same as Handler, but return RouteError instead of panic,
+// if the subject is invalid or conflicts with the registered routes.
+func (mux *Mux) TryHandler(subject string, h HandleFunc, mw ...Middleware) error {
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+
+	return mux.registerHandler("", subject, h, "", mw...)
+}
+
+// registerHandler use handlerName in Routes, if it is empty, the name is got from h.
+func (mux *Mux) registerHandler(name string, subject string, h HandleFunc, handlerName string, mw ...Middleware) error {
+	if template, exist := mux.shared.routeNames[name]; exist {
+		return newRouteError(ErrDuplicatedRoute, mux.node.fullSubject+subject, "assign duplicated route name %q: registered by %q", name, template)
+	}
+
+	predicates, mw := splitPredicates(mw)
 	param := &paramHandler{
-		handler: h,
+		handler:     h,
+		handlerName: handlerName,
+		predicates:  predicates,
 	}
 	if mw != nil {
 		param.handler = Link(param.handler, mw...)
-		param.handlerName = functionName(h)
+		if handlerName == "" {
+			param.handlerName = functionName(h)
+		}
+		param.handlerMiddlewareNames = middlewareNames(mw)
 	}
 
-	mux.node.addRoute(subject, 0, param, []Middleware{})
+	leafNode, err := mux.node.addRoute(subject, 0, param, []Middleware{})
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		leafNode.routeName = name
+		mux.shared.routeNames[name] = leafNode.fullSubject
+	}
+	return nil
+}
+
+// HandlerForType
+// fn must be a function with the signature func(message *Message, body T, dep any) error,
+// T is the dynamic type of Message.Body, it can also be an interface.
+//
+// When no handler is found by subject, the handler is selected by the type of Message.Body,
+// before DefaultHandler and NotFoundHandler.
+// Like DefaultHandler, the deepest type handler on the searching path is used.
+//
+//	mux.Group("orders/").
+//		HandlerForType(func(msg *Message, ev OrderCreated, dep any) error { ... })
+func (mux *Mux) HandlerForType(fn any, mw ...Middleware) *Mux {
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+
+	h, err := newTypeHandler(fn, mw...)
+	if err != nil {
+		mux.shared.handleRouteError(newRouteError(ErrInvalidRoute, mux.node.fullSubject, "%v", err))
+		return mux
+	}
+
+	param := &paramHandler{
+		typeHandler: h,
+	}
+
+	_, err = mux.node.addRoute("", 0, param, []Middleware{})
+	mux.shared.handleRouteError(err)
 	return mux
 }
 
+// HandlerByNumber
+// The route can also be found by integer lookup in HandleNumber.
 func (mux *Mux) HandlerByNumber(subject int, h HandleFunc, mw ...Middleware) *Mux {
-	return mux.Handler(strconv.Itoa(subject)+mux.routeDelimiter, h, mw...)
+	return mux.handlerByNumber(subject, h, "", mw...)
+}
+
+func (mux *Mux) handlerByNumber(subject int, h HandleFunc, handlerName string, mw ...Middleware) *Mux {
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+
+	err := mux.registerHandler("", strconv.Itoa(subject)+mux.routeDelimiter, h, handlerName, mw...)
+	if err != nil {
+		mux.shared.handleRouteError(err)
+		return mux
+	}
+	mux.node.addNumberRoute(subject)
+	return mux
 }
 
 func (mux *Mux) Group(groupName string) *Mux {
-	groupNode := mux.node.addRoute(groupName, 0, nil, []Middleware{})
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+
+	return mux.group(groupName)
+}
+
+func (mux *Mux) group(groupName string) *Mux {
+	groupNode, err := mux.node.addRoute(groupName, 0, nil, []Middleware{})
+	if err != nil {
+		mux.shared.handleRouteError(err)
+		groupNode = mux.node.newChild(mux.node.fullSubject + groupName) // detached, the error has been collected
+	}
 	return &Mux{
 		node:              groupNode,
 		routeDelimiter:    mux.routeDelimiter,
 		errorHandlers:     mux.errorHandlers,
 		enableMessagePool: mux.enableMessagePool,
+		shared:            mux.shared,
 	}
 }
 
+// Mount
+// Graft the handlers of src under the prefix of mux,
+// includes middlewares, default handlers, not found handlers, transforms and error handlers of src.
+//
+// The handlers of src are copied, so registering handler into src after mounting won't affect mux.
+// Middlewares of src only apply to the handlers of src,
+// and the error handlers of src are executed before the middlewares of mux.
+// The route names of src are kept with the prefixed subject, so they can be used by Mux.BuildSubject of mux,
+// if a name has been registered by mux, it is reported as ErrDuplicatedRoute.
+func (mux *Mux) Mount(prefix string, src *Mux) *Mux {
+	if src.shared == mux.shared {
+		panic("Mount: can't mount the mux itself or its group")
+	}
+	if src.routeDelimiter != mux.routeDelimiter || src.node.syntax != mux.node.syntax {
+		mux.shared.Lock()
+		defer mux.shared.Unlock()
+		err := newRouteError(ErrInvalidRoute, mux.node.fullSubject+prefix, "Mount: route delimiter and syntax of src must be the same as mux")
+		mux.shared.handleRouteError(err)
+		return mux
+	}
+
+	if src.shared.lazyMiddleware {
+		src.shared.resolveMiddleware()
+	}
+
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+	src.shared.RLock()
+	defer src.shared.RUnlock()
+
+	wrap := func(next HandleFunc) HandleFunc { return next }
+	if src.errorHandlers != nil {
+		errorHandlers := src.errorHandlers
+		wrap = func(next HandleFunc) HandleFunc {
+			return func(message *Message, dep any) error {
+				err := next(message, dep)
+				return handleError(err, errorHandlers)(message, dep)
+			}
+		}
+	}
+
+	mountNode, err := mux.node.addRoute(prefix, 0, nil, []Middleware{})
+	if err != nil {
+		mux.shared.handleRouteError(err)
+		return mux
+	}
+
+	var path []Middleware
+	if !mountNode.lazy {
+		path = mountNode.middlewares
+	}
+
+	err = mountNode.graft(src.node, path, wrap, mux.shared.routeNames)
+	mux.shared.handleRouteError(err)
+	return mux
+}
+
+func handleError(err error, errorHandlers []Middleware) HandleFunc {
+	return Link(func(message *Message, dep any) error {
+		return err
+	}, errorHandlers...)
+}
+
+// GroupByNumber
+// The group can also be found by integer lookup in HandleNumber.
 func (mux *Mux) GroupByNumber(groupName int) *Mux {
-	return mux.Group(strconv.Itoa(groupName) + mux.routeDelimiter)
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+
+	group := mux.group(strconv.Itoa(groupName) + mux.routeDelimiter)
+	mux.node.addNumberRoute(groupName)
+	return group
 }
 
 // DefaultHandler
@@ -133,15 +532,27 @@ func (mux *Mux) GroupByNumber(groupName int) *Mux {
 // that the 'Default' handler will utilize middleware,
 // whereas 'NotFound' won't use middleware."
 func (mux *Mux) DefaultHandler(h HandleFunc, mw ...Middleware) *Mux {
+	return mux.defaultHandler(h, "", mw...)
+}
+
+func (mux *Mux) defaultHandler(h HandleFunc, handlerName string, mw ...Middleware) *Mux {
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+
 	param := &paramHandler{
-		defaultHandler: h,
+		defaultHandler:     h,
+		defaultHandlerName: handlerName,
 	}
 	if mw != nil {
 		param.defaultHandler = Link(param.defaultHandler, mw...)
-		param.defaultHandlerName = functionName(h)
+		if handlerName == "" {
+			param.defaultHandlerName = functionName(h)
+		}
+		param.defaultHandlerMiddlewareNames = middlewareNames(mw)
 	}
 
-	mux.node.addRoute("", 0, param, []Middleware{})
+	_, err := mux.node.addRoute("", 0, param, []Middleware{})
+	mux.shared.handleRouteError(err)
 	return mux
 }
 
@@ -152,27 +563,160 @@ func (mux *Mux) DefaultHandler(h HandleFunc, mw ...Middleware) *Mux {
 // that the 'Default' handler will utilize middleware,
 // whereas 'NotFound' won't use middleware."
 func (mux *Mux) NotFoundHandler(h HandleFunc) *Mux {
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+
 	param := &paramHandler{
 		notFoundHandler: h,
 	}
 
-	mux.node.addRoute("", 0, param, []Middleware{})
+	_, err := mux.node.addRoute("", 0, param, []Middleware{})
+	mux.shared.handleRouteError(err)
 	return mux
 }
 
 func (mux *Mux) ErrorHandler(errHandlers ...Middleware) *Mux {
+	mux.shared.Lock()
+	defer mux.shared.Unlock()
+
 	mux.errorHandlers = append(mux.errorHandlers, errHandlers...)
 	return mux
 }
 
 func (mux *Mux) EnableMessagePool() *Mux {
+	mux.shared.Lock()
+	defer mux.shared.Unlock()
+
 	mux.enableMessagePool = true
 	return mux
 }
 
+// CollectRouteErrors
+// When the subject is invalid or conflicts with the registered routes, registration panics by default.
+// After enabling it, the mux and its groups collect RouteError instead, and Validate returns them.
+// It is useful when routes are loaded from plugins or config.
+func (mux *Mux) CollectRouteErrors() *Mux {
+	mux.shared.Lock()
+	defer mux.shared.Unlock()
+
+	mux.shared.collectRouteErrors = true
+	return mux
+}
+
+// Validate return RouteErrors which describe every conflicting subject, or nil if there is no conflict.
+// It includes the errors collected by CollectRouteErrors,
+// and the routes which can never be matched, because a sibling wildcard with the same shape has higher priority,
+// e.g. "users/{name}" is shadowed by "users/{id}".
+func (mux *Mux) Validate() error {
+	mux.shared.RLock()
+	defer mux.shared.RUnlock()
+
+	shadowed := mux.shared.root.shadowedRoutes()
+	sort.Slice(shadowed, func(i, j int) bool {
+		return shadowed[i].Subject < shadowed[j].Subject
+	})
+
+	errs := append(RouteErrors{}, mux.shared.routeErrors...)
+	errs = append(errs, shadowed...)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// RemoveHandler
+// Subject must be the same as the subject used by Handler, e.g. "users/{id}".
+// If the handler isn't found, return ErrNotFoundSubject.
+func (mux *Mux) RemoveHandler(subject string) error {
+	mux.shared.Lock()
+	defer mux.shared.unlockAfterRegister()
+
+	for name, template := range mux.shared.routeNames {
+		if template == mux.node.fullSubject+subject {
+			delete(mux.shared.routeNames, name)
+		}
+	}
+
+	_, err := mux.node.removeRoute(subject, 0)
+	return err
+}
+
+func (mux *Mux) RemoveHandlerByNumber(subject int) error {
+	err := mux.RemoveHandler(strconv.Itoa(subject) + mux.routeDelimiter)
+	if err != nil {
+		return err
+	}
+
+	mux.shared.Lock()
+	defer mux.shared.Unlock()
+	mux.node.removeNumberRoute(subject)
+	return nil
+}
+
+// BuildSubject produce the concrete subject from a registered route,
+// route is a name of NamedHandler or a subject template of Handler, e.g. "Hello/{user}".
+// The template is relative to the mux, but the concrete subject includes the prefix of groups.
+//
+// If route isn't registered, return ErrNotFoundSubject.
+// If params are missing, unknown or don't satisfy the wildcards, return ErrInvalidRouteParam.
+//
+//	mux.Group("v1/").NamedHandler("hello", "Hello/{user}", Hello)
+//
+//	subject, err := mux.BuildSubject("hello", map[string]any{"user": "caesar"})
+//	subject => "v1/Hello/caesar"
+func (mux *Mux) BuildSubject(route string, params map[string]any) (string, error) {
+	mux.shared.RLock()
+	defer mux.shared.RUnlock()
+
+	template, ok := mux.shared.routeNames[route]
+	if !ok {
+		template = mux.node.fullSubject + route
+	}
+
+	buf, used, err := mux.shared.root.buildSubject(template, 0, params, make([]byte, 0, len(template)))
+	if err != nil {
+		return "", err
+	}
+
+	// the same param may be used more than once, e.g. "a/{id}/b/{id}"
+	if len(used) != len(params) {
+		isUsed := make(map[string]bool, len(used))
+		for _, key := range used {
+			isUsed[key] = true
+		}
+
+		unknown := make([]string, 0, len(params))
+		for key := range params {
+			if !isUsed[key] {
+				unknown = append(unknown, key)
+			}
+		}
+		if len(unknown) != 0 {
+			sort.Strings(unknown)
+			return "", ErrorWrapWithMessage(ErrInvalidRouteParam, "template=%q: unknown params %q", template, unknown)
+		}
+	}
+	return string(buf), nil
+}
+
+// Routes get register handler structured information, it can be exported by Routes.JSON or Routes.Markdown.
+func (mux *Mux) Routes() Routes {
+	if mux.shared.lazyMiddleware {
+		mux.shared.resolveMiddleware()
+	}
+
+	mux.shared.RLock()
+	defer mux.shared.RUnlock()
+	return mux.node.routes()
+}
+
 // Endpoints get register handler function information
 func (mux *Mux) Endpoints(action func(subject, handler string)) {
-	for _, v := range mux.node.endpoint() {
+	mux.shared.RLock()
+	endpoints := mux.node.endpoint()
+	mux.shared.RUnlock()
+
+	for _, v := range endpoints {
 		action(v[0], v[1])
 	}
 }
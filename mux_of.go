@@ -0,0 +1,201 @@
+package art
+
+import (
+	"reflect"
+)
+
+// HandleFuncOf is a HandleFunc with the statically typed dependency.
+type HandleFuncOf[D any] func(message *Message, dep D) error
+
+// HandleFunc convert to HandleFunc.
+// If the dependency isn't D, return ErrInvalidDependency.
+// A nil dependency is passed as the zero value of D.
+func (h HandleFuncOf[D]) HandleFunc() HandleFunc {
+	return func(message *Message, dep any) error {
+		d, err := assertDependency[D](dep)
+		if err != nil {
+			return err
+		}
+		return h(message, d)
+	}
+}
+
+func (h HandleFuncOf[D]) PreMiddleware() Middleware {
+	return h.HandleFunc().PreMiddleware()
+}
+
+func (h HandleFuncOf[D]) PostMiddleware() Middleware {
+	return h.HandleFunc().PostMiddleware()
+}
+
+func assertDependency[D any](dep any) (d D, err error) {
+	if dep == nil {
+		return d, nil
+	}
+	d, ok := dep.(D)
+	if !ok {
+		return d, ErrorWrapWithMessage(ErrInvalidDependency, "got %T, want %v", dep, reflect.TypeOf((*D)(nil)).Elem())
+	}
+	return d, nil
+}
+
+// NewMuxOf create a MuxOf with the typed dependency D.
+//
+//	mux := art.NewMuxOf[*KafkaProducer]("/")
+//	mux.Handler("orders/{id}", func(msg *art.Message, producer *KafkaProducer) error { ... })
+func NewMuxOf[D any](routeDelimiter string) *MuxOf[D] {
+	return MuxOfMux[D](NewMux(routeDelimiter))
+}
+
+// MuxOfMux wrap an existing Mux, e.g. NewMQTTMux or NewNATSMux.
+func MuxOfMux[D any](mux *Mux) *MuxOf[D] {
+	return &MuxOf[D]{mux: mux}
+}
+
+// MuxOf keeps the trie, middleware and group features of Mux,
+// but handlers receive the statically typed dependency D instead of any.
+//
+// Adapter passes its application, which may be decorated by AdapterOption.DecorateAdapter, as the dependency,
+// so D can be the decorated application type, and MuxOf.Mux is passed to AdapterOption.IngressMux or AdapterOption.EgressMux.
+type MuxOf[D any] struct {
+	mux *Mux
+}
+
+// Mux return the underlying Mux, which shares the routes with MuxOf.
+// If MuxOf is nil, return nil.
+func (mux *MuxOf[D]) Mux() *Mux {
+	if mux == nil {
+		return nil
+	}
+	return mux.mux
+}
+
+func (mux *MuxOf[D]) HandleMessage(message *Message, dependency D) error {
+	return mux.mux.HandleMessage(message, dependency)
+}
+
+func (mux *MuxOf[D]) HandleNumber(message *Message, dependency D, numbers ...int) error {
+	return mux.mux.HandleNumber(message, dependency, numbers...)
+}
+
+func (mux *MuxOf[D]) Middleware(middlewares ...Middleware) *MuxOf[D] {
+	mux.mux.Middleware(middlewares...)
+	return mux
+}
+
+func (mux *MuxOf[D]) PreMiddleware(handleFuncs ...HandleFuncOf[D]) *MuxOf[D] {
+	for _, h := range handleFuncs {
+		mux.mux.Middleware(h.PreMiddleware())
+	}
+	return mux
+}
+
+func (mux *MuxOf[D]) PostMiddleware(handleFuncs ...HandleFuncOf[D]) *MuxOf[D] {
+	for _, h := range handleFuncs {
+		mux.mux.Middleware(h.PostMiddleware())
+	}
+	return mux
+}
+
+func (mux *MuxOf[D]) EnableLazyMiddleware() *MuxOf[D] {
+	mux.mux.EnableLazyMiddleware()
+	return mux
+}
+
+func (mux *MuxOf[D]) Transform(transform HandleFuncOf[D]) *MuxOf[D] {
+	mux.mux.Transform(transform.HandleFunc())
+	return mux
+}
+
+func (mux *MuxOf[D]) Handler(subject string, h HandleFuncOf[D], mw ...Middleware) *MuxOf[D] {
+	return mux.NamedHandler("", subject, h, mw...)
+}
+
+func (mux *MuxOf[D]) NamedHandler(name string, subject string, h HandleFuncOf[D], mw ...Middleware) *MuxOf[D] {
+	shared := mux.mux.shared
+	shared.Lock()
+	defer shared.unlockAfterRegister()
+
+	err := mux.mux.registerHandler(name, subject, h.HandleFunc(), functionName(h), mw...)
+	shared.handleRouteError(err)
+	return mux
+}
+
+func (mux *MuxOf[D]) TryHandler(subject string, h HandleFuncOf[D], mw ...Middleware) error {
+	shared := mux.mux.shared
+	shared.Lock()
+	defer shared.unlockAfterRegister()
+
+	return mux.mux.registerHandler("", subject, h.HandleFunc(), functionName(h), mw...)
+}
+
+func (mux *MuxOf[D]) HandlerByNumber(subject int, h HandleFuncOf[D], mw ...Middleware) *MuxOf[D] {
+	mux.mux.handlerByNumber(subject, h.HandleFunc(), functionName(h), mw...)
+	return mux
+}
+
+func (mux *MuxOf[D]) Group(groupName string) *MuxOf[D] {
+	return MuxOfMux[D](mux.mux.Group(groupName))
+}
+
+func (mux *MuxOf[D]) GroupByNumber(groupName int) *MuxOf[D] {
+	return MuxOfMux[D](mux.mux.GroupByNumber(groupName))
+}
+
+func (mux *MuxOf[D]) Mount(prefix string, src *MuxOf[D]) *MuxOf[D] {
+	mux.mux.Mount(prefix, src.mux)
+	return mux
+}
+
+func (mux *MuxOf[D]) DefaultHandler(h HandleFuncOf[D], mw ...Middleware) *MuxOf[D] {
+	mux.mux.defaultHandler(h.HandleFunc(), functionName(h), mw...)
+	return mux
+}
+
+func (mux *MuxOf[D]) NotFoundHandler(h HandleFuncOf[D]) *MuxOf[D] {
+	mux.mux.NotFoundHandler(h.HandleFunc())
+	return mux
+}
+
+func (mux *MuxOf[D]) ErrorHandler(errHandlers ...Middleware) *MuxOf[D] {
+	mux.mux.ErrorHandler(errHandlers...)
+	return mux
+}
+
+func (mux *MuxOf[D]) EnableMessagePool() *MuxOf[D] {
+	mux.mux.EnableMessagePool()
+	return mux
+}
+
+func (mux *MuxOf[D]) CollectRouteErrors() *MuxOf[D] {
+	mux.mux.CollectRouteErrors()
+	return mux
+}
+
+func (mux *MuxOf[D]) Validate() error {
+	return mux.mux.Validate()
+}
+
+func (mux *MuxOf[D]) RemoveHandler(subject string) error {
+	return mux.mux.RemoveHandler(subject)
+}
+
+func (mux *MuxOf[D]) RemoveHandlerByNumber(subject int) error {
+	return mux.mux.RemoveHandlerByNumber(subject)
+}
+
+func (mux *MuxOf[D]) BuildSubject(route string, params map[string]any) (string, error) {
+	return mux.mux.BuildSubject(route, params)
+}
+
+func (mux *MuxOf[D]) Routes() Routes {
+	return mux.mux.Routes()
+}
+
+func (mux *MuxOf[D]) Endpoints(action func(subject, handler string)) {
+	mux.mux.Endpoints(action)
+}
+
+func (mux *MuxOf[D]) Compile() *CompiledMux {
+	return mux.mux.Compile()
+}
@@ -0,0 +1,103 @@
+package art
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type testApp struct {
+	IAdapter
+	name string
+}
+
+func TestMuxOf_HandleMessage(t *testing.T) {
+	recorder := []string{}
+
+	mux := NewMuxOf[*testApp]("/").
+		PreMiddleware(func(message *Message, app *testApp) error {
+			recorder = append(recorder, "pre "+app.name)
+			return nil
+		}).
+		DefaultHandler(func(message *Message, app *testApp) error {
+			recorder = append(recorder, "default "+message.Subject)
+			return nil
+		})
+
+	mux.Group("users/").
+		Handler("{id}", func(message *Message, app *testApp) error {
+			recorder = append(recorder, fmt.Sprintf("user %v by %v", message.ParamString("id"), app.name))
+			return nil
+		})
+
+	app := &testApp{name: "caesar"}
+	for _, subject := range []string{"users/1017", "orders"} {
+		err := mux.HandleMessage(&Message{Subject: subject, RouteParam: map[string]any{}}, app)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	expected := []string{
+		"pre caesar",
+		"user 1017 by caesar",
+		"pre caesar",
+		"default orders",
+	}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+
+	err := mux.Mux().HandleMessage(&Message{Subject: "users/1", RouteParam: map[string]any{}}, "wrong dependency")
+	if !errors.Is(err, ErrInvalidDependency) {
+		t.Errorf("expected ErrInvalidDependency: got %v", err)
+	}
+}
+
+func TestMuxOf_Routes(t *testing.T) {
+	mux := NewMuxOf[*testApp]("/").
+		Handler("users/{id}", endpointOf).
+		DefaultHandler(endpointOf, routes_mw())
+
+	routes := mux.Routes()
+	for _, route := range routes {
+		if route.Handler != functionName(endpointOf) {
+			t.Errorf("%q: unexpected handler name: got %v", route.Subject, route.Handler)
+		}
+	}
+}
+
+func endpointOf(_ *Message, _ *testApp) error {
+	return nil
+}
+
+func TestMuxOf_when_adapter_decorated(t *testing.T) {
+	recorder := []string{}
+
+	egressMux := NewMuxOf[*testApp]("/").
+		Handler("hello", func(message *Message, app *testApp) error {
+			recorder = append(recorder, app.name+" "+app.Identifier())
+			return nil
+		})
+
+	adp, err := NewAdapterOption().
+		Identifier("1017").
+		EgressMux(egressMux.Mux()).
+		DecorateAdapter(func(adp IAdapter) IAdapter {
+			return &testApp{IAdapter: adp, name: "decorated"}
+		}).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = adp.(*testApp).IAdapter.(Producer).Send(&Message{Subject: "hello"})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := []string{"decorated 1017"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+}
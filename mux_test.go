@@ -2,9 +2,13 @@ package art
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 func TestMessageMux_HandleMessage(t *testing.T) {
@@ -503,7 +507,7 @@ func TestMux_RouteParam_when_wildcard_subject(t *testing.T) {
 			return nil
 		}).
 		Handler("order/{user_id}", func(message *Message, dep any) error {
-			actual = append(actual, message.RouteParam.Str("user_id"))
+			actual = append(actual, message.ParamString("user_id"))
 			return nil
 		}).
 		Handler("/get/test/abc/", func(message *Message, dep any) error {
@@ -511,19 +515,19 @@ func TestMux_RouteParam_when_wildcard_subject(t *testing.T) {
 			return nil
 		}).
 		Handler("/get/{param}/abc/", func(message *Message, dep any) error {
-			actual = append(actual, message.RouteParam.Str("param"))
+			actual = append(actual, message.ParamString("param"))
 			return nil
 		}).
 		Handler("{kind}/book/{book_id}", func(message *Message, dep any) error {
-			actual = append(actual, message.RouteParam.Str("kind")+" "+message.RouteParam.Str("book_id"))
+			actual = append(actual, message.ParamString("kind")+" "+message.ParamString("book_id"))
 			return nil
 		}).
 		Handler("dev/book/{book_id}", func(message *Message, dep any) error {
-			actual = append(actual, "dev book "+message.RouteParam.Str("book_id"))
+			actual = append(actual, "dev book "+message.ParamString("book_id"))
 			return nil
 		}).
 		Handler("dev/ebook/{book_id}", func(message *Message, dep any) error {
-			actual = append(actual, "dev ebook "+message.RouteParam.Str("book_id"))
+			actual = append(actual, "dev ebook "+message.ParamString("book_id"))
 			return nil
 		})
 
@@ -590,7 +594,6 @@ func TestMessageMux_Recover(t *testing.T) {
 		).
 		DefaultHandler(func(_ *Message, dep any) error {
 			panic("dependency is nil")
-			return nil
 		})
 
 	message := &Message{
@@ -599,3 +602,1237 @@ func TestMessageMux_Recover(t *testing.T) {
 
 	mux.HandleMessage(message, nil)
 }
+
+func TestMux_RouteParam_when_catch_all_subject(t *testing.T) {
+	mux := NewMux("/")
+
+	actual := []string{}
+	mux.
+		DefaultHandler(func(message *Message, dep any) error {
+			actual = append(actual, "default "+message.Subject)
+			return nil
+		}).
+		Handler("files/{path...}", func(message *Message, dep any) error {
+			actual = append(actual, "files "+message.ParamString("path"))
+			return nil
+		}).
+		Handler("files/{name}/meta", func(message *Message, dep any) error {
+			actual = append(actual, "meta "+message.ParamString("name"))
+			return nil
+		}).
+		Handler("files/static", func(message *Message, dep any) error {
+			actual = append(actual, "static")
+			return nil
+		})
+
+	mux.Group("v1/{kind}/").
+		Handler("{rest...}", func(message *Message, dep any) error {
+			actual = append(actual, message.ParamString("kind")+" "+message.ParamString("rest"))
+			return nil
+		})
+
+	expectedSubjects := []string{
+		".*",
+		"files/static",
+		"files/{name}/meta",
+		"files/{path...}",
+		"v1/{kind}/{rest...}",
+	}
+
+	i := 0
+	mux.Endpoints(func(subject, handler string) {
+		if subject != expectedSubjects[i] {
+			t.Errorf("unexpected output: got %s, want %s", subject, expectedSubjects[i])
+		}
+		i++
+	})
+
+	expectedResponse := []string{
+		"files a/b/c",
+		"files ",
+		"meta a",
+		"files a/meta/b",
+		"static",
+		"files static/x",
+		"game rpg/1017",
+		"default v2/x",
+	}
+
+	messages := []*Message{
+		{Subject: "files/a/b/c", RouteParam: map[string]any{}},
+		{Subject: "files/", RouteParam: map[string]any{}},
+		{Subject: "files/a/meta", RouteParam: map[string]any{}},
+		{Subject: "files/a/meta/b", RouteParam: map[string]any{}},
+		{Subject: "files/static", RouteParam: map[string]any{}},
+		{Subject: "files/static/x", RouteParam: map[string]any{}},
+		{Subject: "v1/game/rpg/1017", RouteParam: map[string]any{}},
+		{Subject: "v2/x", RouteParam: map[string]any{}},
+	}
+
+	for i, message := range messages {
+		err := mux.HandleMessage(message, nil)
+		if err != nil {
+			t.Errorf("%v: unexpected error: got %v", message.Subject, err)
+			break
+		}
+		if actual[i] != expectedResponse[i] {
+			t.Errorf("%v: unexpected output: got %s, want %s", message.Subject, actual[i], expectedResponse[i])
+			break
+		}
+	}
+}
+
+func TestMux_RouteParam_when_constrained_subject(t *testing.T) {
+	mux := NewMux("/")
+
+	actual := []string{}
+	mux.
+		NotFoundHandler(func(message *Message, dep any) error {
+			actual = append(actual, "not found "+message.Subject)
+			return nil
+		}).
+		Handler("users/{id:int}", func(message *Message, dep any) error {
+			actual = append(actual, fmt.Sprintf("int %T %v", message.Params().Get("id"), message.Params().Int("id")))
+			return nil
+		}).
+		Handler("users/{id:uuid}", func(message *Message, dep any) error {
+			actual = append(actual, fmt.Sprintf("uuid %x", message.Params().Get("id")))
+			return nil
+		}).
+		Handler("users/{name}", func(message *Message, dep any) error {
+			actual = append(actual, "name "+message.ParamString("name"))
+			return nil
+		}).
+		Handler(`orders/{code:[A-Z]{3}-\d+}`, func(message *Message, dep any) error {
+			actual = append(actual, "code "+message.ParamString("code"))
+			return nil
+		}).
+		Handler(`items/{id:ulid}/detail`, func(message *Message, dep any) error {
+			actual = append(actual, fmt.Sprintf("ulid %T", message.Params().Get("id")))
+			return nil
+		})
+
+	expectedResponse := []string{
+		"int int 1017",
+		"uuid 3f2504e04f8941d39a0c0305e82c3301",
+		"name caesar",
+		"code ABC-123",
+		"not found orders/AB-123",
+		"ulid ulid.ULID",
+		"not found items/123/detail",
+	}
+
+	messages := []*Message{
+		{Subject: "users/1017", RouteParam: map[string]any{}},
+		{Subject: "users/3F2504E0-4F89-41D3-9A0C-0305E82C3301", RouteParam: map[string]any{}},
+		{Subject: "users/caesar", RouteParam: map[string]any{}},
+		{Subject: "orders/ABC-123", RouteParam: map[string]any{}},
+		{Subject: "orders/AB-123", RouteParam: map[string]any{}},
+		{Subject: "items/01HV6QYJTC3ZV2G5NNAYXW8VBN/detail", RouteParam: map[string]any{}},
+		{Subject: "items/123/detail", RouteParam: map[string]any{}},
+	}
+
+	for i, message := range messages {
+		err := mux.HandleMessage(message, nil)
+		if err != nil {
+			t.Errorf("%v: unexpected error: got %v", message.Subject, err)
+			break
+		}
+		if actual[i] != expectedResponse[i] {
+			t.Errorf("%v: unexpected output: got %s, want %s", message.Subject, actual[i], expectedResponse[i])
+			break
+		}
+	}
+}
+
+func TestMux_RouteParam_when_sibling_wildcard(t *testing.T) {
+	mux := NewMux("/")
+
+	actual := []string{}
+	record := func(format string, keys ...string) HandleFunc {
+		return func(message *Message, dep any) error {
+			values := []any{}
+			for _, key := range keys {
+				values = append(values, message.Params().Get(key))
+			}
+			actual = append(actual, fmt.Sprintf(format, values...))
+			return nil
+		}
+	}
+
+	mux.
+		Handler("users/{id}/orders", record("orders %v", "id")).
+		Handler("users/{name}/profile", record("profile %v", "name")).
+		Handler("{a}/x/{b}/end", record("end %v %v", "a", "b")).
+		Handler("{c}/x/y/other", record("other %v", "c")).
+		Handler("p/{rest...}", record("rest %v", "rest")).
+		Handler("p/{name}", record("name %v", "name")).
+		Handler("p/{id:int}", record("int %v", "id")).
+		Handler("p/static", record("static"))
+
+	mux.Group("g/{id}/").
+		DefaultHandler(record("default %v", "id"))
+
+	expectedResponse := []string{
+		"orders 1017",
+		"profile caesar",
+		"end k y",
+		"other k",
+		"static",
+		"int 12",
+		"name ab",
+		"rest ab/cd",
+		"default 99",
+	}
+
+	messages := []*Message{
+		{Subject: "users/1017/orders", RouteParam: map[string]any{}},
+		{Subject: "users/caesar/profile", RouteParam: map[string]any{}},
+		{Subject: "k/x/y/end", RouteParam: map[string]any{}},
+		{Subject: "k/x/y/other", RouteParam: map[string]any{}},
+		{Subject: "p/static", RouteParam: map[string]any{}},
+		{Subject: "p/12", RouteParam: map[string]any{}},
+		{Subject: "p/ab", RouteParam: map[string]any{}},
+		{Subject: "p/ab/cd", RouteParam: map[string]any{}},
+		{Subject: "g/99/unknown", RouteParam: map[string]any{}},
+	}
+
+	for i, message := range messages {
+		err := mux.HandleMessage(message, nil)
+		if err != nil {
+			t.Errorf("%v: unexpected error: got %v", message.Subject, err)
+			break
+		}
+		if actual[i] != expectedResponse[i] {
+			t.Errorf("%v: unexpected output: got %s, want %s", message.Subject, actual[i], expectedResponse[i])
+			break
+		}
+	}
+
+	message := &Message{Subject: "users/1017/unknown", RouteParam: map[string]any{}}
+	err := mux.HandleMessage(message, nil)
+	if !errors.Is(err, ErrNotFoundSubject) {
+		t.Errorf("%v: unexpected error: got %v", message.Subject, err)
+	}
+	if len(message.Params()) != 0 {
+		t.Errorf("%v: unexpected route param: got %v", message.Subject, message.Params())
+	}
+}
+
+func TestMQTTMux_HandleMessage(t *testing.T) {
+	mux := NewMQTTMux()
+
+	actual := []string{}
+	record := func(name string) HandleFunc {
+		return func(message *Message, dep any) error {
+			actual = append(actual, fmt.Sprintf("%v %v", name, map[string]any(message.Params())))
+			return nil
+		}
+	}
+
+	mux.
+		NotFoundHandler(record("not found")).
+		Handler("sensors/+/temp", record("temp")).
+		Handler("sensors/#", record("sensors")).
+		Handler("sensors/kitchen/temp", record("kitchen")).
+		Handler("#", record("all"))
+
+	mux.Group("home/").
+		Handler("+/+/light", record("light"))
+
+	expectedSubjects := []string{
+		"#",
+		"home/+/+/light",
+		"sensors/#",
+		"sensors/+/temp",
+		"sensors/kitchen/temp",
+	}
+
+	i := 0
+	mux.Endpoints(func(subject, handler string) {
+		if subject != expectedSubjects[i] {
+			t.Errorf("unexpected output: got %s, want %s", subject, expectedSubjects[i])
+		}
+		i++
+	})
+
+	expectedResponse := []string{
+		"kitchen map[]",
+		"temp map[0:room]",
+		"sensors map[0:room/humidity]",
+		"sensors map[0:]",
+		"light map[0:1F 1:room]",
+		"all map[0:other/x]",
+		"not found map[]",
+	}
+
+	messages := []*Message{
+		{Subject: "sensors/kitchen/temp", RouteParam: map[string]any{}},
+		{Subject: "sensors/room/temp", RouteParam: map[string]any{}},
+		{Subject: "sensors/room/humidity", RouteParam: map[string]any{}},
+		{Subject: "sensors", RouteParam: map[string]any{}},
+		{Subject: "home/1F/room/light", RouteParam: map[string]any{}},
+		{Subject: "other/x", RouteParam: map[string]any{}},
+		{Subject: "$SYS/broker", RouteParam: map[string]any{}},
+	}
+
+	for i, message := range messages {
+		err := mux.HandleMessage(message, nil)
+		if err != nil {
+			t.Errorf("%v: unexpected error: got %v", message.Subject, err)
+			break
+		}
+		if actual[i] != expectedResponse[i] {
+			t.Errorf("%v: unexpected output: got %s, want %s", message.Subject, actual[i], expectedResponse[i])
+			break
+		}
+	}
+}
+
+func TestNATSMux_HandleMessage(t *testing.T) {
+	mux := NewNATSMux()
+
+	actual := []string{}
+	record := func(name string) HandleFunc {
+		return func(message *Message, dep any) error {
+			actual = append(actual, fmt.Sprintf("%v %v", name, map[string]any(message.Params())))
+			return nil
+		}
+	}
+
+	mux.
+		DefaultHandler(record("default")).
+		Handler("orders.*.created", record("created")).
+		Handler("orders.>", record("orders"))
+
+	mux.Group("users.").
+		Handler("*.login", record("login")).
+		Group("*.").
+		Handler(">", record("users"))
+
+	expectedSubjects := []string{
+		".*",
+		"orders.*.created",
+		"orders.>",
+		"users.*.>",
+		"users.*.login",
+	}
+
+	i := 0
+	mux.Endpoints(func(subject, handler string) {
+		if subject != expectedSubjects[i] {
+			t.Errorf("unexpected output: got %s, want %s", subject, expectedSubjects[i])
+		}
+		i++
+	})
+
+	expectedResponse := []string{
+		"created map[0:tw]",
+		"orders map[0:tw.deleted]",
+		"orders map[0:created]",
+		"default map[]",
+		"default map[]",
+		"login map[0:1017]",
+		"users map[0:1017 1:2.logout.v1]",
+		"default map[]",
+	}
+
+	messages := []*Message{
+		{Subject: "orders.tw.created", RouteParam: map[string]any{}},
+		{Subject: "orders.tw.deleted", RouteParam: map[string]any{}},
+		{Subject: "orders.created", RouteParam: map[string]any{}},
+		{Subject: "orders", RouteParam: map[string]any{}},
+		{Subject: "orders.", RouteParam: map[string]any{}},
+		{Subject: "users.1017.login", RouteParam: map[string]any{}},
+		{Subject: "users.1017.2.logout.v1", RouteParam: map[string]any{}},
+		{Subject: "users..login", RouteParam: map[string]any{}},
+	}
+
+	for i, message := range messages {
+		err := mux.HandleMessage(message, nil)
+		if err != nil {
+			t.Errorf("%v: unexpected error: got %v", message.Subject, err)
+			break
+		}
+		if actual[i] != expectedResponse[i] {
+			t.Errorf("%v: unexpected output: got %s, want %s", message.Subject, actual[i], expectedResponse[i])
+			break
+		}
+	}
+}
+
+func TestMux_RemoveHandler(t *testing.T) {
+	recorder := []string{}
+	record := func(message *Message, dep any) error {
+		recorder = append(recorder, message.Subject)
+		return nil
+	}
+
+	mux := NewMux("/")
+	mux.Handler("users/{id}", record)
+	mux.Handler("users/{id}/orders", record)
+
+	v1 := mux.Group("v1/")
+	v1.Handler("hello", record)
+
+	err := mux.RemoveHandler("users/{id}/orders")
+	if err != nil {
+		t.Errorf("unexpected error: got %v", err)
+	}
+
+	err = mux.RemoveHandler("users/{name}")
+	if !errors.Is(err, ErrNotFoundSubject) {
+		t.Errorf("unexpected error: got %v", err)
+	}
+
+	err = v1.RemoveHandler("hello")
+	if err != nil {
+		t.Errorf("unexpected error: got %v", err)
+	}
+	v1.Handler("world", record)
+
+	expectedSubjects := []string{
+		"users/{id}",
+		"v1/world",
+	}
+
+	i := 0
+	mux.Endpoints(func(subject, handler string) {
+		if subject != expectedSubjects[i] {
+			t.Errorf("unexpected output: got %s, want %s", subject, expectedSubjects[i])
+		}
+		i++
+	})
+
+	for _, subject := range []string{"users/1/orders", "v1/hello"} {
+		err = mux.HandleMessage(&Message{Subject: subject, RouteParam: map[string]any{}}, nil)
+		if !errors.Is(err, ErrNotFoundSubject) {
+			t.Errorf("%v: unexpected error: got %v", subject, err)
+		}
+	}
+
+	for _, subject := range []string{"users/1", "v1/world"} {
+		err = mux.HandleMessage(&Message{Subject: subject, RouteParam: map[string]any{}}, nil)
+		if err != nil {
+			t.Errorf("%v: unexpected error: got %v", subject, err)
+		}
+	}
+}
+
+func TestMux_Handler_when_concurrent_HandleMessage(t *testing.T) {
+	mux := NewMux("/").EnableMessagePool()
+	mux.Handler("static", UseSkipMessage())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				message := GetMessage()
+				message.Subject = "static"
+				err := mux.HandleMessage(message, nil)
+				if err != nil {
+					t.Errorf("unexpected error: got %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	for j := 0; j < 500; j++ {
+		subject := "plugin/" + strconv.Itoa(j)
+		mux.Handler(subject, UseSkipMessage())
+		err := mux.RemoveHandler(subject)
+		if err != nil {
+			t.Errorf("unexpected error: got %v", err)
+		}
+	}
+	wg.Wait()
+}
+
+func TestMux_Transform_when_use_mux_while_registering(t *testing.T) {
+	mux := NewMux("/")
+	entered := make(chan struct{})
+	registered := make(chan struct{})
+
+	mux.Group("orders/").
+		Transform(func(message *Message, dep any) error {
+			close(entered)
+			// wait for the registration which is blocked by the searching
+			time.Sleep(20 * time.Millisecond)
+			subject, err := mux.BuildSubject("orders/{id}", map[string]any{"id": 1})
+			if err != nil {
+				return err
+			}
+			message.Subject = subject
+			return nil
+		}).
+		Handler("{id}", UseSkipMessage(), When(func(message *Message, dep any) bool {
+			return len(mux.Routes()) > 0
+		}))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- mux.HandleMessage(&Message{Subject: "orders/1017"}, nil)
+	}()
+
+	<-entered
+	go func() {
+		mux.Handler("plugin", UseSkipMessage())
+		close(registered)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("deadlock: Transform uses the mux while registration is waiting")
+	}
+	<-registered
+}
+
+func TestMux_EnableLazyMiddleware(t *testing.T) {
+	recorder := []string{}
+	record := func(message *Message, dep any) error {
+		recorder = append(recorder, string(message.Bytes))
+		return nil
+	}
+	wrap := func(mark string) Middleware {
+		return HandleFunc(func(message *Message, dep any) error {
+			message.Bytes = []byte(fmt.Sprintf("%v%s%v", mark, message.Bytes, mark))
+			return nil
+		}).PreMiddleware()
+	}
+
+	mux := NewMux("/").EnableLazyMiddleware()
+
+	mux.Handler("topic1", record)
+	v1 := mux.Group("v1/")
+	v1.Handler("topic2", record)
+
+	mux.Middleware(wrap("*"))
+	v1.Middleware(wrap("&"))
+
+	expectedRecords := []string{
+		"*topic1*",
+		"&*v1/topic2*&",
+	}
+
+	for i, subject := range []string{"topic1", "v1/topic2"} {
+		message := &Message{Subject: subject, Bytes: []byte(subject)}
+		err := mux.HandleMessage(message, nil)
+		if err != nil {
+			t.Errorf("%v: unexpected error: got %v", subject, err)
+			break
+		}
+		if recorder[i] != expectedRecords[i] {
+			t.Errorf("%v: unexpected output: got %s, want %s", subject, recorder[i], expectedRecords[i])
+			break
+		}
+	}
+
+	// register after the first dispatch
+	v1.Handler("topic3", record)
+	message := &Message{Subject: "v1/topic3", Bytes: []byte("v1/topic3")}
+	err := mux.HandleMessage(message, nil)
+	if err != nil {
+		t.Errorf("unexpected error: got %v", err)
+	}
+	if expected := "&*v1/topic3*&"; recorder[2] != expected {
+		t.Errorf("unexpected output: got %s, want %s", recorder[2], expected)
+	}
+}
+
+func TestMux_Middleware_when_registered_after_handler(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(mux *Mux)
+	}{
+		{
+			name: "handler",
+			setup: func(mux *Mux) {
+				mux.Handler("topic1", UseSkipMessage()).Middleware(UseRecover())
+			},
+		},
+		{
+			name: "group",
+			setup: func(mux *Mux) {
+				mux.Group("v1/")
+				mux.PreMiddleware(UseSkipMessage())
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			tt.setup(NewMux("/"))
+		})
+	}
+}
+
+func TestMux_Mount(t *testing.T) {
+	recorder := []string{}
+	record := func(message *Message, dep any) error {
+		recorder = append(recorder, fmt.Sprintf("%s %v", message.Bytes, map[string]any(message.Params())))
+		return nil
+	}
+	wrap := func(mark string) Middleware {
+		return HandleFunc(func(message *Message, dep any) error {
+			message.Bytes = []byte(fmt.Sprintf("%v%s%v", mark, message.Bytes, mark))
+			return nil
+		}).PreMiddleware()
+	}
+
+	errBilling := errors.New("billing fail")
+	billing := NewMux("/").
+		ErrorHandler(func(next HandleFunc) HandleFunc {
+			return func(message *Message, dep any) error {
+				err := next(message, dep)
+				if err != nil {
+					return fmt.Errorf("billing: %w", err)
+				}
+				return nil
+			}
+		}).
+		Middleware(wrap("$")).
+		DefaultHandler(record).
+		Handler("invoices/{id}", record).
+		Handler("fail", func(message *Message, dep any) error { return errBilling })
+
+	mux := NewMux("/").
+		Middleware(wrap("*")).
+		Handler("hello", record).
+		Mount("billing/", billing)
+
+	expectedSubjects := []string{
+		"billing/.*",
+		"billing/fail",
+		"billing/invoices/{id}",
+		"hello",
+	}
+
+	i := 0
+	mux.Endpoints(func(subject, handler string) {
+		if subject != expectedSubjects[i] {
+			t.Errorf("unexpected output: got %s, want %s", subject, expectedSubjects[i])
+		}
+		i++
+	})
+
+	expectedRecords := []string{
+		"*hello* map[]",
+		"$*billing/invoices/1*$ map[id:1]",
+		"$*billing/unknown*$ map[]",
+	}
+
+	for i, subject := range []string{"hello", "billing/invoices/1", "billing/unknown"} {
+		message := &Message{Subject: subject, Bytes: []byte(subject), RouteParam: map[string]any{}}
+		err := mux.HandleMessage(message, nil)
+		if err != nil {
+			t.Errorf("%v: unexpected error: got %v", subject, err)
+			break
+		}
+		if recorder[i] != expectedRecords[i] {
+			t.Errorf("%v: unexpected output: got %s, want %s", subject, recorder[i], expectedRecords[i])
+			break
+		}
+	}
+
+	err := mux.HandleMessage(&Message{Subject: "billing/fail", RouteParam: map[string]any{}}, nil)
+	if !errors.Is(err, errBilling) || err.Error() != "billing: billing fail" {
+		t.Errorf("unexpected error: got %v", err)
+	}
+}
+
+func TestMux_Mount_when_named_route(t *testing.T) {
+	billing := NewMux("/").
+		NamedHandler("inv", "invoices/{id}", UseSkipMessage())
+
+	mux := NewMux("/").
+		CollectRouteErrors().
+		Mount("billing/", billing)
+
+	subject, err := mux.BuildSubject("inv", map[string]any{"id": 1017})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if subject != "billing/invoices/1017" {
+		t.Errorf("unexpected subject: got %v", subject)
+	}
+
+	var name string
+	for _, route := range mux.Routes() {
+		if route.Subject == "billing/invoices/{id}" {
+			name = route.Name
+		}
+	}
+	if name != "inv" {
+		t.Errorf("unexpected route name: got %q", name)
+	}
+
+	mux.Mount("archive/", billing)
+	err = mux.Validate()
+	expected := `subject="archive/invoices/{id}": assign duplicated route name "inv": registered by "billing/invoices/{id}": duplicated route`
+	if !errors.Is(err, ErrDuplicatedRoute) || err.Error() != expected {
+		t.Errorf("unexpected error: got %v, want %v", err, expected)
+	}
+}
+
+func TestMux_Mount_when_group(t *testing.T) {
+	billing := NewMux("/")
+	billing.Group("g/").Handler("b", UseSkipMessage())
+
+	mux := NewMux("/").Mount("billing/", billing)
+
+	var groupPrefix string
+	for _, route := range mux.Routes() {
+		if route.Subject == "billing/g/b" {
+			groupPrefix = route.GroupPrefix
+		}
+	}
+	if groupPrefix != "billing/g/" {
+		t.Errorf("unexpected group prefix: got %q", groupPrefix)
+	}
+
+	// the grafted group node isn't deleted, because it is a group of src
+	err := mux.RemoveHandler("billing/g/b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mux.Handler("billing/g/c", UseSkipMessage())
+	for _, route := range mux.Routes() {
+		if route.Subject == "billing/g/c" && route.GroupPrefix != "billing/g/" {
+			t.Errorf("unexpected group prefix: got %q", route.GroupPrefix)
+		}
+	}
+}
+
+func TestMux_BuildSubject(t *testing.T) {
+	mux := NewMux("/")
+	v1 := mux.Group("v1/{region}/")
+	v1.NamedHandler("hello", "Hello/{user}", UseSkipMessage())
+	v1.Handler("orders/{id:int}/{path...}", UseSkipMessage())
+	mux.Handler("a/{id}/b/{id}", UseSkipMessage())
+
+	mqtt := NewMQTTMux().
+		NamedHandler("sensors", "sensors/+/#", UseSkipMessage())
+
+	tests := []struct {
+		name      string
+		mux       *Mux
+		route     string
+		params    map[string]any
+		expected  string
+		expectErr error
+	}{
+		{
+			name:     "by name",
+			mux:      mux,
+			route:    "hello",
+			params:   map[string]any{"region": "tw", "user": "caesar"},
+			expected: "v1/tw/Hello/caesar",
+		},
+		{
+			name:     "repeated param",
+			mux:      mux,
+			route:    "a/{id}/b/{id}",
+			params:   map[string]any{"id": 1},
+			expected: "a/1/b/1",
+		},
+		{
+			name:     "by template of group",
+			mux:      v1,
+			route:    "orders/{id:int}/{path...}",
+			params:   map[string]any{"region": "jp", "id": 1017, "path": "a/b"},
+			expected: "v1/jp/orders/1017/a/b",
+		},
+		{
+			name:     "mqtt match parent",
+			mux:      mqtt,
+			route:    "sensors",
+			params:   map[string]any{"0": "kitchen", "1": ""},
+			expected: "sensors/kitchen",
+		},
+		{
+			name:      "typo template",
+			mux:       mux,
+			route:     "v1/{region}/Helo/{user}",
+			params:    map[string]any{"region": "tw", "user": "caesar"},
+			expectErr: ErrNotFoundSubject,
+		},
+		{
+			name:      "missing param",
+			mux:       mux,
+			route:     "hello",
+			params:    map[string]any{"user": "caesar"},
+			expectErr: ErrInvalidRouteParam,
+		},
+		{
+			name:      "unknown param",
+			mux:       mux,
+			route:     "hello",
+			params:    map[string]any{"region": "tw", "user": "caesar", "age": 18},
+			expectErr: ErrInvalidRouteParam,
+		},
+		{
+			name:      "unsatisfied constraint",
+			mux:       v1,
+			route:     "orders/{id:int}/{path...}",
+			params:    map[string]any{"region": "jp", "id": "x", "path": "a"},
+			expectErr: ErrInvalidRouteParam,
+		},
+		{
+			name:      "param contains delimiter",
+			mux:       mux,
+			route:     "hello",
+			params:    map[string]any{"region": "tw/jp", "user": "caesar"},
+			expectErr: ErrInvalidRouteParam,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			subject, err := tt.mux.BuildSubject(tt.route, tt.params)
+			if !errors.Is(err, tt.expectErr) {
+				t.Errorf("unexpected error: got %v, want %v", err, tt.expectErr)
+				return
+			}
+			if subject != tt.expected {
+				t.Errorf("unexpected output: got %s, want %s", subject, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMux_TryHandler(t *testing.T) {
+	mux := NewMux("/")
+	mux.Group("v1/").Handler("users/{id}", UseSkipMessage())
+
+	tests := []struct {
+		name        string
+		mux         *Mux
+		subject     string
+		wantKind    error
+		wantSubject string
+	}{
+		{
+			name:        "duplicated handler",
+			mux:         mux,
+			subject:     "v1/users/{id}",
+			wantKind:    ErrDuplicatedRoute,
+			wantSubject: "v1/users/{id}",
+		},
+		{
+			name:        "lack wildcard close",
+			mux:         mux.Group("v2/"),
+			subject:     "users/{id",
+			wantKind:    ErrInvalidRoute,
+			wantSubject: "v2/users/{id",
+		},
+		{
+			name:        "catch-all wildcard is not at the end",
+			mux:         mux,
+			subject:     "files/{path...}/raw",
+			wantKind:    ErrInvalidRoute,
+			wantSubject: "files/{path...}/raw",
+		},
+		{
+			name:        "empty delimiter",
+			mux:         NewMux(""),
+			subject:     "users/{id}",
+			wantKind:    ErrInvalidRoute,
+			wantSubject: "users/{id}",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.mux.TryHandler(tt.subject, UseSkipMessage())
+			if !errors.Is(err, tt.wantKind) {
+				t.Fatalf("unexpected error: got %v, want %v", err, tt.wantKind)
+			}
+
+			var routeErr *RouteError
+			if !errors.As(err, &routeErr) {
+				t.Fatalf("expected RouteError: got %T", err)
+			}
+			if routeErr.Subject != tt.wantSubject {
+				t.Errorf("unexpected subject: got %q, want %q", routeErr.Subject, tt.wantSubject)
+			}
+		})
+	}
+
+	err := mux.TryHandler("v1/users/{id}/orders", UseSkipMessage())
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMux_Validate(t *testing.T) {
+	mux := NewMux("/").CollectRouteErrors()
+
+	mux.Handler("users/{id}", UseSkipMessage())
+	mux.Handler("users/{id}", UseSkipMessage())
+	mux.Handler("users/{name}", UseSkipMessage())
+	mux.Handler("users/{id:int}/orders", UseSkipMessage())
+	mux.Handler("users/{no:int}/orders", UseSkipMessage())
+	mux.Handler("users/{no:int}/profile", UseSkipMessage())
+	mux.Handler("orders/{code", UseSkipMessage())
+	mux.Group("files/{path...}/").Handler("raw", UseSkipMessage())
+	mux.Transform(UseSkipMessage()).Transform(UseSkipMessage())
+
+	expected := []string{
+		`subject="users/{id}": assign duplicated handler: registered by github.com/KScaesar/art.UseSkipMessage.func1: duplicated route`,
+		`subject="orders/{code": lack wildcard '}': invalid route`,
+		`subject="files/{path...}/": catch-all wildcard must be at the end: invalid route`,
+		`subject="": assign duplicated transform: duplicated route`,
+		`subject="users/{name}": shadowed by "users/{id}": duplicated route`,
+		`subject="users/{no:int}/orders": shadowed by "users/{id:int}/orders": duplicated route`,
+	}
+
+	err := mux.Validate()
+	var errs RouteErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected RouteErrors: got %v", err)
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("unexpected errors count: got %v, want %v\n%v", len(errs), len(expected), err)
+	}
+	for i, want := range expected {
+		if errs[i].Error() != want {
+			t.Errorf("unexpected error: got %s, want %s", errs[i].Error(), want)
+		}
+	}
+
+	if NewMux("/").Handler("users/{id}", UseSkipMessage()).Validate() != nil {
+		t.Errorf("expected no error")
+	}
+}
+
+func TestMux_ParamString_when_subject_reused(t *testing.T) {
+	var user, code string
+	var id int
+	mux := NewMux("/").EnableMessagePool()
+	mux.Handler("users/{user}/orders/{id:int}/{code:[A-Z]+}", func(message *Message, dep any) error {
+		var err error
+		id, err = message.ParamInt("id")
+		if err != nil {
+			return err
+		}
+
+		// the buffer of subject is reused by adapter
+		buf := unsafe.Slice(unsafe.StringData(message.Subject), len(message.Subject))
+		copy(buf, "xxxxxxxxxxxxxxxxxxxxxxxxxxxx")
+
+		user = message.ParamString("user")
+		code = message.ParamString("code")
+		params := message.Params()
+		if params.Str("user") != "caesar" || params.Str("code") != "ABC" {
+			t.Errorf("unexpected Params: got %v", params)
+		}
+		return nil
+	})
+
+	subject := []byte("users/caesar/orders/1017/ABC")
+	message := GetMessage()
+	message.Subject = unsafe.String(&subject[0], len(subject))
+
+	err := mux.HandleMessage(message, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user != "caesar" || id != 1017 || code != "ABC" {
+		t.Errorf("unexpected param: got %v %v %v", user, id, code)
+	}
+
+	message = GetMessage()
+	if message.ParamString("user") != "" {
+		t.Errorf("unexpected param after PutMessage: %v", message.ParamString("user"))
+	}
+	_, err = message.ParamInt("id")
+	if !errors.Is(err, ErrInvalidRouteParam) {
+		t.Errorf("unexpected error: got %v, want %v", err, ErrInvalidRouteParam)
+	}
+}
+
+func TestMux_HandleMessage_when_route_param_without_allocation(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+
+	var user string
+	mux := NewMux("/").EnableMessagePool()
+	mux.Handler("users/{user}/orders/{id}/{code:[A-Z]+}", func(message *Message, dep any) error {
+		user = message.ParamString("user")
+		_, err := message.ParamInt("id")
+		return err
+	})
+	compiled := mux.Compile()
+
+	for _, handle := range []HandleFunc{mux.HandleMessage, compiled.HandleMessage} {
+		allocs := testing.AllocsPerRun(100, func() {
+			message := GetMessage()
+			message.Subject = "users/caesar/orders/1017/ABC"
+			err := handle(message, nil)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("unexpected allocs: got %v, want 0", allocs)
+		}
+		if user != "caesar" {
+			t.Errorf("unexpected param: got %v", user)
+		}
+	}
+}
+
+func TestMux_Params_when_message_reused(t *testing.T) {
+	var values []any
+	mux := NewMux("/").EnableMessagePool()
+	mux.Handler("users/{user}", func(message *Message, dep any) error {
+		values = append(values, message.Params().Get("user"))
+		return nil
+	})
+
+	for _, subject := range []string{"users/caesar", "users/kevin"} {
+		message := GetMessage()
+		message.Subject = subject
+		err := mux.HandleMessage(message, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if values[0] != "caesar" || values[1] != "kevin" {
+		t.Errorf("unexpected Params: got %v", values)
+	}
+}
+
+func TestMux_HandleNumber(t *testing.T) {
+	recorder := []string{}
+	record := func(name string) HandleFunc {
+		return func(message *Message, dep any) error {
+			recorder = append(recorder, fmt.Sprintf("%v %v", name, message.Subject))
+			return nil
+		}
+	}
+	errFail := errors.New("fail")
+
+	mux := NewMux("/").
+		PreMiddleware(record("mw")).
+		ErrorHandler(func(next HandleFunc) HandleFunc {
+			return func(message *Message, dep any) error {
+				err := next(message, dep)
+				if err != nil {
+					recorder = append(recorder, fmt.Sprintf("error %v", err))
+				}
+				return nil
+			}
+		}).
+		DefaultHandler(record("default")).
+		HandlerByNumber(1, record("h1")).
+		HandlerByNumber(2, func(message *Message, dep any) error { return errFail })
+
+	mux.GroupByNumber(3).
+		HandlerByNumber(5, record("h3/5"))
+
+	mux.GroupByNumber(4).
+		Transform(func(message *Message, dep any) error {
+			message.Subject += string(message.Bytes)
+			return nil
+		}).
+		Handler("x", record("h4/x"))
+
+	tests := []struct {
+		numbers  []int
+		bytes    string
+		expected []string
+	}{
+		{numbers: []int{1}, expected: []string{"mw 1/", "h1 1/"}},
+		{numbers: []int{2}, expected: []string{"mw 2/", "error fail"}},
+		{numbers: []int{3, 5}, expected: []string{"mw 3/5/", "h3/5 3/5/"}},
+		{numbers: []int{3}, expected: []string{"mw 3/", "default 3/"}},
+		{numbers: []int{9}, expected: []string{"mw 9/", "default 9/"}},
+		{numbers: []int{4}, bytes: "x", expected: []string{"mw 4/x", "h4/x 4/x"}},
+	}
+
+	for _, tt := range tests {
+		recorder = []string{}
+		message := &Message{Bytes: []byte(tt.bytes), RouteParam: map[string]any{}}
+		err := mux.HandleNumber(message, nil, tt.numbers...)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tt.numbers, err)
+		}
+		if fmt.Sprint(recorder) != fmt.Sprint(tt.expected) {
+			t.Errorf("%v: unexpected output: got %v, want %v", tt.numbers, recorder, tt.expected)
+		}
+	}
+
+	err := mux.RemoveHandlerByNumber(1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recorder = []string{}
+	mux.HandleNumber(&Message{RouteParam: map[string]any{}}, nil, 1)
+	expected := []string{"mw 1/", "default 1/"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+}
+
+func TestMux_Handler_when_meta_predicate(t *testing.T) {
+	recorder := []string{}
+	record := func(name string) HandleFunc {
+		return func(message *Message, dep any) error {
+			recorder = append(recorder, name)
+			return nil
+		}
+	}
+
+	mux := NewMux("/").
+		PreMiddleware(record("mw")).
+		Handler("orders", record("v2"), WhenMeta("version", "2")).
+		Handler("orders", record("v3 created"), WhenMeta("version", "3"), WhenMeta("type", "created")).
+		Handler("orders", record("v1")).
+		Handler("users/{id}", record("admin"), When(func(message *Message, dep any) bool {
+			return message.Metadata.Str("role") == "admin"
+		})).
+		Handler("users/{name}", record("name")).
+		DefaultHandler(record("default"))
+
+	tests := []struct {
+		subject  string
+		metadata map[string]any
+		expected []string
+	}{
+		{subject: "orders", metadata: map[string]any{"version": "2"}, expected: []string{"mw", "v2"}},
+		{subject: "orders", metadata: map[string]any{"version": "3", "type": "created"}, expected: []string{"mw", "v3 created"}},
+		{subject: "orders", metadata: map[string]any{"version": "3"}, expected: []string{"mw", "v1"}},
+		{subject: "orders", metadata: map[string]any{}, expected: []string{"mw", "v1"}},
+		{subject: "users/1", metadata: map[string]any{"role": "admin"}, expected: []string{"mw", "admin"}},
+		{subject: "users/1", metadata: map[string]any{}, expected: []string{"mw", "name"}},
+	}
+
+	for _, compiled := range []bool{false, true} {
+		handle := mux.HandleMessage
+		if compiled {
+			handle = mux.Compile().HandleMessage
+		}
+
+		for _, tt := range tests {
+			recorder = []string{}
+			message := &Message{Subject: tt.subject, Metadata: tt.metadata, RouteParam: map[string]any{}}
+			err := handle(message, nil)
+			if err != nil {
+				t.Errorf("%v %v: unexpected error: %v", tt.subject, tt.metadata, err)
+			}
+			if fmt.Sprint(recorder) != fmt.Sprint(tt.expected) {
+				t.Errorf("compiled=%v %v %v: unexpected output: got %v, want %v", compiled, tt.subject, tt.metadata, recorder, tt.expected)
+			}
+		}
+	}
+
+	conditional := 0
+	for _, route := range mux.Routes() {
+		if route.Conditional {
+			conditional++
+		}
+	}
+	if conditional != 3 {
+		t.Errorf("unexpected conditional routes: got %v, want 3", conditional)
+	}
+
+	err := mux.RemoveHandler("orders")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recorder = []string{}
+	mux.HandleMessage(&Message{Subject: "orders", Metadata: map[string]any{"version": "2"}, RouteParam: map[string]any{}}, nil)
+	expected := []string{"mw", "default"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+}
+
+func TestMux_HandlerForType(t *testing.T) {
+	recorder := []string{}
+	record := func(name string) HandleFunc {
+		return func(message *Message, dep any) error {
+			recorder = append(recorder, name)
+			return nil
+		}
+	}
+
+	mux := NewMux("/").
+		PreMiddleware(record("mw")).
+		Handler("orders/{id}/created", record("created")).
+		DefaultHandler(record("default")).
+		HandlerForType(func(msg *Message, ev testOrderCreated, dep any) error {
+			recorder = append(recorder, "root type "+ev.Id)
+			return nil
+		})
+
+	mux.Group("orders/{id}/").
+		HandlerForType(func(msg *Message, ev testOrderCreated, dep any) error {
+			recorder = append(recorder, fmt.Sprintf("group type %v %v", ev.Id, msg.ParamString("id")))
+			return nil
+		})
+
+	tests := []struct {
+		subject  string
+		body     any
+		expected []string
+	}{
+		{subject: "orders/1/created", body: testOrderCreated{Id: "1"}, expected: []string{"mw", "created"}},
+		{subject: "orders/1/unknown", body: testOrderCreated{Id: "1"}, expected: []string{"mw", "group type 1 1"}},
+		{subject: "unknown", body: testOrderCreated{Id: "2"}, expected: []string{"mw", "root type 2"}},
+		{subject: "unknown", body: testOrderCanceled{Id: "3"}, expected: []string{"mw", "default"}},
+		{subject: "unknown", body: nil, expected: []string{"mw", "default"}},
+	}
+
+	for _, compiled := range []bool{false, true} {
+		handle := mux.HandleMessage
+		if compiled {
+			handle = mux.Compile().HandleMessage
+		}
+
+		for _, tt := range tests {
+			recorder = []string{}
+			err := handle(&Message{Subject: tt.subject, Body: tt.body, RouteParam: map[string]any{}}, nil)
+			if err != nil {
+				t.Errorf("%v: unexpected error: %v", tt.subject, err)
+			}
+			if fmt.Sprint(recorder) != fmt.Sprint(tt.expected) {
+				t.Errorf("compiled=%v %v: unexpected output: got %v, want %v", compiled, tt.subject, recorder, tt.expected)
+			}
+		}
+	}
+
+	err := NewMux("/").
+		HandlerForType(func(msg *Message, ev testOrderCreated, dep any) error { return nil }).
+		TryHandler("x", UseSkipMessage())
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	endpoints := []string{}
+	mux.Endpoints(func(subject, handler string) {
+		endpoints = append(endpoints, subject)
+	})
+	expected := []string{".(art.testOrderCreated)", ".*", "orders/{id}/.(art.testOrderCreated)", "orders/{id}/created"}
+	if fmt.Sprint(endpoints) != fmt.Sprint(expected) {
+		t.Errorf("unexpected endpoints: got %v, want %v", endpoints, expected)
+	}
+}
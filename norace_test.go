@@ -0,0 +1,5 @@
+//go:build !race
+
+package art
+
+const raceEnabled = false
@@ -0,0 +1,6 @@
+//go:build race
+
+package art
+
+// raceEnabled is used to skip the tests of allocation, the race detector allocates on its own.
+const raceEnabled = true
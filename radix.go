@@ -0,0 +1,159 @@
+package art
+
+import (
+	"sort"
+	"strings"
+)
+
+// radixNode is the frozen and path-compressed form of trie, it is built by Mux.Compile.
+// Consecutive static nodes without handler, transform and wildcard are merged into one prefix,
+// so the matching semantics are the same as trie.
+type radixNode struct {
+	prefix      string // static chars from parent to node, wildcard node has no prefix
+	indices     string // the first char of each staticChild
+	staticChild []*radixNode
+
+	wildcardChild []*radixNode // sorted by priority, the same as trie
+	wildcard      *wildcard
+
+	delimiter           string
+	transform           HandleFunc
+	handler             HandleFunc
+	conditionalHandlers []*conditionalHandler
+	typeHandlers        typeRegistry
+	defaultHandler      HandleFunc
+	notFoundHandler     HandleFunc
+}
+
+func compileRadix(node *trie) *radixNode {
+	radix := &radixNode{
+		wildcard:        node.wildcard,
+		delimiter:       node.delimiter,
+		transform:       node.transform,
+		handler:         node.handler,
+		defaultHandler:  node.defaultHandler,
+		notFoundHandler: node.notFoundHandler,
+	}
+
+	for _, c := range node.conditionalHandlers {
+		conditional := *c
+		radix.conditionalHandlers = append(radix.conditionalHandlers, &conditional)
+	}
+
+	node.typeHandlers.each(func(h *typeHandler) {
+		handler := *h
+		radix.typeHandlers.add(&handler)
+	})
+
+	chars := make([]byte, 0, len(node.staticChild))
+	for char := range node.staticChild {
+		chars = append(chars, char)
+	}
+	sort.Slice(chars, func(i, j int) bool { return chars[i] < chars[j] })
+
+	for _, char := range chars {
+		prefix := []byte{char}
+		child := node.staticChild[char]
+		for child.isCompressible() {
+			for next, grandchild := range child.staticChild {
+				prefix = append(prefix, next)
+				child = grandchild
+			}
+		}
+
+		compiled := compileRadix(child)
+		compiled.prefix = string(prefix)
+		radix.indices += string(char)
+		radix.staticChild = append(radix.staticChild, compiled)
+	}
+
+	for _, child := range node.wildcardChild {
+		radix.wildcardChild = append(radix.wildcardChild, compileRadix(child))
+	}
+	return radix
+}
+
+// isCompressible return true, if node can be merged into the prefix of radixNode.
+func (node *trie) isCompressible() bool {
+	return node.handler == nil &&
+		node.conditionalHandlers == nil &&
+		node.typeHandlers.isEmpty() &&
+		node.defaultHandler == nil &&
+		node.notFoundHandler == nil &&
+		node.transform == nil &&
+		len(node.wildcardChild) == 0 &&
+		len(node.staticChild) == 1
+}
+
+func (node *radixNode) findHandler(subject string, search *trieSearch, message *Message, dep any) (HandleFunc, []routeParam, error) {
+	handler, err := node.search(subject, 0, search, message, dep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if handler != nil {
+		return handler, search.params, nil
+	}
+	if search.typeHandler != nil {
+		return search.typeHandler, search.typeParams, nil
+	}
+	if search.defaultHandler != nil {
+		return search.defaultHandler, search.defaultParams, nil
+	}
+	if search.notFoundHandler != nil {
+		return search.notFoundHandler, nil, nil
+	}
+	return nil, nil, ErrNotFoundSubject
+}
+
+// search is the same as trie.search, but it matches a static prefix at once.
+func (node *radixNode) search(subject string, cursor int, search *trieSearch, message *Message, dep any) (HandleFunc, error) {
+	subject, err := search.arrive(subject, cursor, message, dep, node.transform, &node.typeHandlers, node.defaultHandler, node.notFoundHandler)
+	if err != nil {
+		return nil, err
+	}
+
+	// for static route
+	if cursor == len(subject) {
+		if handler := search.matchHandler(node.conditionalHandlers, node.handler, message, dep); handler != nil {
+			return handler, nil
+		}
+	}
+	if node.delimiter != "" || cursor < len(subject) {
+		char := node.delimiter
+		if cursor < len(subject) {
+			char = subject[cursor : cursor+1]
+		}
+
+		if i := strings.IndexByte(node.indices, char[0]); i >= 0 {
+			child := node.staticChild[i]
+			rest := subject[cursor:]
+
+			var handler HandleFunc
+			var err error
+			if strings.HasPrefix(rest, child.prefix) {
+				handler, err = child.search(subject, cursor+len(child.prefix), search, message, dep)
+			} else if child.isParentOf(rest) {
+				handler, err = searchParentMatcher(child.wildcardChild, subject, len(subject), search, message, dep)
+			}
+			if handler != nil || err != nil {
+				return handler, err
+			}
+		}
+	}
+
+	// for wildcard route
+	return searchWildcardChild(node.wildcardChild, node.delimiter, subject, cursor, search, message, dep)
+}
+
+func (node *radixNode) wildcardOf() *wildcard { return node.wildcard }
+
+// isParentOf return true, if rest is the prefix of node without the last delimiter,
+// e.g. the subject "sport" is the parent level of mqtt "sport/#".
+func (node *radixNode) isParentOf(rest string) bool {
+	n := len(node.prefix) - 1
+	return node.delimiter != "" &&
+		len(rest) == n &&
+		node.prefix[n] == node.delimiter[0] &&
+		node.prefix[:n] == rest
+}
@@ -0,0 +1,201 @@
+package art
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type RateLimitMode uint8
+
+const (
+	RateLimitReject RateLimitMode = iota // return ErrRateLimited
+	RateLimitDelay                       // wait for the token, until Message.Ctx is done
+	RateLimitDrop                        // skip the message and return nil
+)
+
+// UseRateLimit is a token bucket, which is tracked per Message.Subject by default.
+// Use KeyByParam, KeyByAdapter or KeyFunc to track per other key,
+// e.g. KeyByAdapter limits each WebSocket client managed in the Hub.
+//
+//	mux.Middleware(art.UseRateLimit{}.Limit(10, time.Second).Burst(20).KeyByAdapter().Middleware())
+type UseRateLimit struct {
+	limit   int
+	per     time.Duration
+	burst   int
+	mode    RateLimitMode
+	keyFunc func(message *Message, dep any) string
+}
+
+// Limit allow n messages per duration.
+func (use UseRateLimit) Limit(n int, per time.Duration) UseRateLimit {
+	use.limit = n
+	use.per = per
+	return use
+}
+
+// Burst is the capacity of the bucket, default is the n of Limit.
+func (use UseRateLimit) Burst(n int) UseRateLimit {
+	use.burst = n
+	return use
+}
+
+// Mode default is RateLimitReject.
+func (use UseRateLimit) Mode(mode RateLimitMode) UseRateLimit {
+	use.mode = mode
+	return use
+}
+
+func (use UseRateLimit) KeyBySubject() UseRateLimit {
+	use.keyFunc = nil
+	return use
+}
+
+// KeyByParam track per the value of the route param, e.g. "user_id" of "users/{user_id}".
+func (use UseRateLimit) KeyByParam(key string) UseRateLimit {
+	use.keyFunc = func(message *Message, _ any) string {
+		return message.ParamString(key)
+	}
+	return use
+}
+
+// KeyByAdapter track per the Identifier of dep, it is the adapter which handles the message.
+func (use UseRateLimit) KeyByAdapter() UseRateLimit {
+	use.keyFunc = func(_ *Message, dep any) string {
+		adp, ok := dep.(interface{ Identifier() string })
+		if !ok {
+			return ""
+		}
+		return adp.Identifier()
+	}
+	return use
+}
+
+func (use UseRateLimit) KeyFunc(keyFunc func(message *Message, dep any) string) UseRateLimit {
+	use.keyFunc = keyFunc
+	return use
+}
+
+func (use UseRateLimit) Middleware() Middleware {
+	if use.limit <= 0 || use.per <= 0 {
+		panic("UseRateLimit: limit and duration must be positive")
+	}
+	if use.burst <= 0 {
+		use.burst = use.limit
+	}
+	if use.keyFunc == nil {
+		use.keyFunc = func(message *Message, _ any) string { return message.Subject }
+	}
+
+	limiter := &rateLimiter{
+		rate:    float64(use.limit) / float64(use.per),
+		burst:   float64(use.burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+
+	return func(next HandleFunc) HandleFunc {
+		return func(message *Message, dep any) error {
+			key := use.keyFunc(message, dep)
+
+			wait, ok := limiter.take(key, time.Now(), use.mode == RateLimitDelay)
+			if !ok {
+				if use.mode == RateLimitDrop {
+					return nil
+				}
+				return ErrorWrapWithMessage(ErrRateLimited, "key=%q", key)
+			}
+
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				if message.Ctx == nil {
+					<-timer.C
+				} else {
+					select {
+					case <-timer.C:
+					case <-message.Ctx.Done():
+						timer.Stop()
+						limiter.cancel(key)
+						return message.Ctx.Err()
+					}
+				}
+			}
+			return next(message, dep)
+		}
+	}
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per nanosecond
+	burst   float64
+	buckets map[string]*tokenBucket
+	calls   int
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take return the waiting time for the token.
+// If reserve is false, the token is taken only if it is available now.
+func (limiter *rateLimiter) take(key string, now time.Time, reserve bool) (wait time.Duration, ok bool) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	limiter.calls++
+	if limiter.calls%1024 == 0 {
+		limiter.sweep(now)
+	}
+
+	bucket, exist := limiter.buckets[key]
+	if !exist {
+		bucket = &tokenBucket{tokens: limiter.burst, last: now}
+		// key may refer to the pooled buffer of message, e.g. Message.ParamString
+		limiter.buckets[strings.Clone(key)] = bucket
+	}
+	limiter.refill(bucket, now)
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return 0, true
+	}
+	if !reserve {
+		return 0, false
+	}
+
+	bucket.tokens--
+	return time.Duration(-bucket.tokens / limiter.rate), true
+}
+
+// cancel return the reserved token, when the waiting is interrupted.
+func (limiter *rateLimiter) cancel(key string) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if bucket, ok := limiter.buckets[key]; ok {
+		bucket.tokens++
+	}
+}
+
+func (limiter *rateLimiter) refill(bucket *tokenBucket, now time.Time) {
+	elapsed := now.Sub(bucket.last)
+	if elapsed <= 0 {
+		return
+	}
+	bucket.last = now
+	bucket.tokens += float64(elapsed) * limiter.rate
+	if bucket.tokens > limiter.burst {
+		bucket.tokens = limiter.burst
+	}
+}
+
+// sweep remove the full buckets, they are the same as new buckets.
+func (limiter *rateLimiter) sweep(now time.Time) {
+	for key, bucket := range limiter.buckets {
+		limiter.refill(bucket, now)
+		if bucket.tokens >= limiter.burst {
+			delete(limiter.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package art
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type testIdentifier string
+
+func (id testIdentifier) Identifier() string { return string(id) }
+
+func TestUseRateLimit(t *testing.T) {
+	recorder := []string{}
+	record := func(message *Message, dep any) error {
+		recorder = append(recorder, message.Subject)
+		return nil
+	}
+
+	reject := Link(record, UseRateLimit{}.Limit(2, time.Minute).Middleware())
+	codes := []int{}
+	for _, subject := range []string{"a", "a", "a", "b"} {
+		codes = append(codes, ErrorExtractCode(reject(&Message{Subject: subject}, nil)))
+	}
+
+	limited := ErrRateLimited.MyCode()
+	expected := []int{0, 0, limited, 0}
+	if fmt.Sprint(codes) != fmt.Sprint(expected) {
+		t.Errorf("unexpected codes: got %v, want %v", codes, expected)
+	}
+
+	drop := Link(record, UseRateLimit{}.Limit(1, time.Minute).Mode(RateLimitDrop).Middleware())
+	for _, subject := range []string{"c", "c"} {
+		err := drop(&Message{Subject: subject}, nil)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+
+	expectedRecorder := []string{"a", "a", "b", "c"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expectedRecorder) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expectedRecorder)
+	}
+}
+
+func TestUseRateLimit_when_delay(t *testing.T) {
+	const interval = 20 * time.Millisecond
+	handler := Link(func(message *Message, dep any) error { return nil },
+		UseRateLimit{}.Limit(1, interval).Mode(RateLimitDelay).Middleware())
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		err := handler(&Message{Subject: "delay", Ctx: context.Background()}, nil)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("expected delay: elapsed %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := handler(&Message{Subject: "delay", Ctx: ctx}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled: got %v", err)
+	}
+}
+
+func TestUseRateLimit_KeyByAdapter(t *testing.T) {
+	handler := Link(func(message *Message, dep any) error { return nil },
+		UseRateLimit{}.Limit(1, time.Minute).KeyByAdapter().Middleware())
+
+	adapters := []testIdentifier{"client1", "client2", "client1"}
+	codes := []int{}
+	for _, adp := range adapters {
+		codes = append(codes, ErrorExtractCode(handler(&Message{Subject: "chat"}, adp)))
+	}
+
+	expected := []int{0, 0, ErrRateLimited.MyCode()}
+	if fmt.Sprint(codes) != fmt.Sprint(expected) {
+		t.Errorf("unexpected codes: got %v, want %v", codes, expected)
+	}
+}
+
+func TestUseRateLimit_KeyByParam(t *testing.T) {
+	recorder := []string{}
+	mux := NewMux("/").
+		Middleware(UseRateLimit{}.Limit(1, time.Minute).KeyByParam("user").Middleware()).
+		Handler("users/{user}/chat", func(message *Message, dep any) error {
+			recorder = append(recorder, message.Subject)
+			return nil
+		})
+
+	for _, subject := range []string{"users/1/chat", "users/2/chat", "users/1/chat"} {
+		mux.HandleMessage(&Message{Subject: subject, RouteParam: map[string]any{}}, nil)
+	}
+
+	expected := []string{"users/1/chat", "users/2/chat"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+}
+
+func TestUseRateLimit_KeyByParam_when_message_pool(t *testing.T) {
+	recorder := []string{}
+	mux := NewMux("/").
+		EnableMessagePool().
+		Middleware(UseRateLimit{}.Limit(1, time.Minute).KeyByParam("room").Middleware()).
+		Handler("users/{user}/rooms/{room}", func(message *Message, dep any) error {
+			recorder = append(recorder, message.Subject)
+			return nil
+		})
+
+	// the params of reused message are stored at the different offset of buffer
+	for _, subject := range []string{"users/a/rooms/xx", "users/bbb/rooms/yy", "users/ccc/rooms/xx"} {
+		message := GetMessage()
+		message.Subject = subject
+		mux.HandleMessage(message, nil)
+	}
+
+	expected := []string{"users/a/rooms/xx", "users/bbb/rooms/yy"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+}
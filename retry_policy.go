@@ -0,0 +1,232 @@
+package art
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+// DefaultRetryPolicy is used by ReliableTask, UseRetry and AdapterOption.RawFixup.
+// The interval starts from 10 seconds, multiplied by 1.5 up to 1 minute, with 0.5 jitter.
+// If retryMaxSecond is 0, retry until the task is allowed to stop.
+func DefaultRetryPolicy(retryMaxSecond int) RetryPolicy {
+	if retryMaxSecond < 0 {
+		retryMaxSecond = 0
+	}
+	return ExponentialRetry(10*time.Second, 1.5, time.Minute).
+		Jitter(0.5).
+		MaxElapsed(time.Duration(retryMaxSecond) * time.Second)
+}
+
+// ExponentialRetry wait initial, initial*multiplier, initial*multiplier^2, ... up to maxInterval.
+// If maxInterval is 0, the interval isn't limited.
+func ExponentialRetry(initial time.Duration, multiplier float64, maxInterval time.Duration) RetryPolicy {
+	return RetryPolicy{
+		newBackOff: func() backoff.BackOff {
+			b := backoff.NewExponentialBackOff()
+			b.InitialInterval = initial
+			b.Multiplier = multiplier
+			b.RandomizationFactor = 0
+			b.MaxElapsedTime = 0
+			if maxInterval > 0 {
+				b.MaxInterval = maxInterval
+			} else {
+				b.MaxInterval = time.Duration(1<<63 - 1)
+			}
+			return b
+		},
+	}
+}
+
+// ConstantRetry wait the same interval between attempts.
+func ConstantRetry(interval time.Duration) RetryPolicy {
+	return RetryPolicy{
+		newBackOff: func() backoff.BackOff {
+			return backoff.NewConstantBackOff(interval)
+		},
+	}
+}
+
+// FibonacciRetry wait initial, initial, initial*2, initial*3, initial*5, ... up to maxInterval.
+// If maxInterval is 0, the interval isn't limited.
+func FibonacciRetry(initial time.Duration, maxInterval time.Duration) RetryPolicy {
+	return RetryPolicy{
+		newBackOff: func() backoff.BackOff {
+			return &fibonacciBackOff{initial: initial, maxInterval: maxInterval}
+		},
+	}
+}
+
+// RetryPolicy decide whether and when a failed task is retried.
+// It is a value, the methods return a modified copy, so a policy can be shared as a template.
+//
+//	policy := art.ExponentialRetry(100*time.Millisecond, 2, 5*time.Second).
+//		MaxAttempts(5).
+//		Jitter(0.2).
+//		RetryIf(func(err error) bool { return !errors.Is(err, ErrInvalidInput) }).
+//		OnRetry(func(attempt int, err error, wait time.Duration) {
+//			logger.Warn("attempt %v: %v: retry after %v", attempt, err, wait)
+//		})
+type RetryPolicy struct {
+	newBackOff  func() backoff.BackOff
+	maxAttempts int
+	maxElapsed  time.Duration
+	jitter      float64
+	retryable   func(err error) bool
+	onRetry     []func(attempt int, err error, wait time.Duration)
+}
+
+// MaxAttempts limit the number of attempts, including the first one.
+// If n is 0, the attempts aren't limited.
+func (policy RetryPolicy) MaxAttempts(n int) RetryPolicy {
+	policy.maxAttempts = n
+	return policy
+}
+
+// MaxElapsed stop retrying, if the next attempt would start after maxElapsed since the first attempt.
+// If maxElapsed is 0, the elapsed time isn't limited.
+func (policy RetryPolicy) MaxElapsed(maxElapsed time.Duration) RetryPolicy {
+	policy.maxElapsed = maxElapsed
+	return policy
+}
+
+// Jitter randomize the interval in [interval*(1-factor), interval*(1+factor)].
+func (policy RetryPolicy) Jitter(factor float64) RetryPolicy {
+	policy.jitter = factor
+	return policy
+}
+
+// RetryIf set the retryable-error classifier.
+// If classifier returns false, the error is returned without retrying.
+func (policy RetryPolicy) RetryIf(classifier func(err error) bool) RetryPolicy {
+	policy.retryable = classifier
+	return policy
+}
+
+// OnRetry add the callback which is called after every failed attempt that will be retried,
+// wait is the interval before the next attempt.
+// It is used for logging and metrics.
+func (policy RetryPolicy) OnRetry(callbacks ...func(attempt int, err error, wait time.Duration)) RetryPolicy {
+	policy.onRetry = append(policy.onRetry[:len(policy.onRetry):len(policy.onRetry)], callbacks...)
+	return policy
+}
+
+// Retry execute task until it succeeds, the error isn't retryable, or the policy stops.
+// allowStop is checked before every attempt, it can be nil.
+func (policy RetryPolicy) Retry(task func() error, allowStop func() bool) error {
+	return policy.retry(nil, task, allowStop)
+}
+
+// RetryContext is the same as Retry, but the waiting is interrupted when ctx is done.
+func (policy RetryPolicy) RetryContext(ctx context.Context, task func() error) error {
+	return policy.retry(ctx, task, nil)
+}
+
+func (policy RetryPolicy) retry(ctx context.Context, task func() error, allowStop func() bool) error {
+	var b backoff.BackOff = &policyBackOff{policy: policy, BackOff: policy.backOff()}
+	if ctx != nil {
+		b = backoff.WithContext(b, ctx)
+	}
+
+	attempt := 0
+	operation := func() error {
+		if allowStop != nil && allowStop() {
+			return backoff.Permanent(errors.New("task has actively been stopped"))
+		}
+		attempt++
+		err := task()
+		if err != nil && policy.retryable != nil && !policy.retryable(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+
+	var notify backoff.Notify
+	if policy.onRetry != nil {
+		notify = func(err error, wait time.Duration) {
+			for _, callback := range policy.onRetry {
+				callback(attempt, err, wait)
+			}
+		}
+	}
+
+	return backoff.RetryNotify(operation, b, notify)
+}
+
+func (policy RetryPolicy) backOff() backoff.BackOff {
+	if policy.newBackOff == nil {
+		return DefaultRetryPolicy(0).newBackOff()
+	}
+	return policy.newBackOff()
+}
+
+// policyBackOff apply MaxAttempts, MaxElapsed and Jitter to the interval of BackOff.
+type policyBackOff struct {
+	backoff.BackOff
+	policy  RetryPolicy
+	start   time.Time
+	attempt int
+}
+
+func (b *policyBackOff) Reset() {
+	b.BackOff.Reset()
+	b.start = time.Now()
+	b.attempt = 0
+}
+
+func (b *policyBackOff) NextBackOff() time.Duration {
+	if b.start.IsZero() {
+		b.start = time.Now()
+	}
+	b.attempt++
+
+	policy := b.policy
+	if policy.maxAttempts > 0 && b.attempt >= policy.maxAttempts {
+		return backoff.Stop
+	}
+
+	wait := b.BackOff.NextBackOff()
+	if wait == backoff.Stop {
+		return backoff.Stop
+	}
+	if policy.jitter > 0 {
+		delta := policy.jitter * float64(wait)
+		wait = time.Duration(float64(wait) - delta + rand.Float64()*2*delta)
+	}
+	if policy.maxElapsed > 0 && time.Since(b.start)+wait > policy.maxElapsed {
+		return backoff.Stop
+	}
+	return wait
+}
+
+type fibonacciBackOff struct {
+	initial     time.Duration
+	maxInterval time.Duration
+	previous    time.Duration
+	current     time.Duration
+}
+
+func (b *fibonacciBackOff) Reset() {
+	b.previous = 0
+	b.current = 0
+}
+
+func (b *fibonacciBackOff) NextBackOff() time.Duration {
+	if b.maxInterval > 0 && b.current >= b.maxInterval {
+		return b.maxInterval
+	}
+
+	if b.current == 0 {
+		b.current = b.initial
+	} else {
+		b.previous, b.current = b.current, b.previous+b.current
+	}
+
+	if b.maxInterval > 0 && b.current > b.maxInterval {
+		return b.maxInterval
+	}
+	return b.current
+}
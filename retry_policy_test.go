@@ -0,0 +1,154 @@
+package art
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_Retry(t *testing.T) {
+	const ms = time.Millisecond
+
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		expected []time.Duration
+	}{
+		{
+			name:     "exponential",
+			policy:   ExponentialRetry(1*ms, 2, 6*ms),
+			expected: []time.Duration{1 * ms, 2 * ms, 4 * ms, 6 * ms},
+		},
+		{
+			name:     "constant",
+			policy:   ConstantRetry(2 * ms),
+			expected: []time.Duration{2 * ms, 2 * ms, 2 * ms, 2 * ms},
+		},
+		{
+			name:     "fibonacci",
+			policy:   FibonacciRetry(1*ms, 4*ms),
+			expected: []time.Duration{1 * ms, 1 * ms, 2 * ms, 3 * ms},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			var waits []time.Duration
+			var retried []int
+
+			policy := tt.policy.
+				MaxAttempts(5).
+				OnRetry(func(attempt int, err error, wait time.Duration) {
+					retried = append(retried, attempt)
+					waits = append(waits, wait)
+				})
+
+			err := policy.Retry(func() error {
+				attempts++
+				return fmt.Errorf("attempt %v", attempts)
+			}, nil)
+
+			if err == nil || err.Error() != "attempt 5" {
+				t.Errorf("unexpected error: got %v", err)
+			}
+			if fmt.Sprint(retried) != fmt.Sprint([]int{1, 2, 3, 4}) {
+				t.Errorf("unexpected attempts: got %v", retried)
+			}
+			if fmt.Sprint(waits) != fmt.Sprint(tt.expected) {
+				t.Errorf("unexpected waits: got %v, want %v", waits, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_when_not_retryable(t *testing.T) {
+	errFatal := errors.New("fatal")
+	attempts := 0
+
+	policy := ConstantRetry(time.Millisecond).
+		RetryIf(func(err error) bool { return !errors.Is(err, errFatal) })
+
+	err := policy.Retry(func() error {
+		attempts++
+		if attempts == 3 {
+			return errFatal
+		}
+		return errors.New("temporary")
+	}, nil)
+
+	if !errors.Is(err, errFatal) || attempts != 3 {
+		t.Errorf("unexpected result: got %v after %v attempts", err, attempts)
+	}
+}
+
+func TestRetryPolicy_Jitter(t *testing.T) {
+	const interval = 10 * time.Millisecond
+	var waits []time.Duration
+
+	policy := ConstantRetry(interval).
+		MaxAttempts(20).
+		Jitter(0.5).
+		OnRetry(func(attempt int, err error, wait time.Duration) {
+			waits = append(waits, wait)
+		})
+
+	policy.Retry(func() error { return errors.New("fail") }, func() bool { return len(waits) >= 3 })
+
+	for _, wait := range waits {
+		if wait < interval/2 || wait > interval*3/2 {
+			t.Errorf("unexpected wait: got %v", wait)
+		}
+	}
+}
+
+func TestUseRetryPolicy_when_ctx_done(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+
+	handler := Link(func(message *Message, dep any) error {
+		attempts++
+		if attempts == 2 {
+			cancel()
+		}
+		return errors.New("fail")
+	}, UseRetryPolicy(ConstantRetry(time.Millisecond)))
+
+	err := handler(&Message{Ctx: ctx}, nil)
+	if err == nil || attempts != 2 {
+		t.Errorf("unexpected result: got %v after %v attempts", err, attempts)
+	}
+}
+
+func TestReliableTaskWithPolicy(t *testing.T) {
+	recorder := []string{}
+	connected := false
+
+	task := func() error {
+		recorder = append(recorder, "task")
+		if !connected {
+			return errors.New("disconnected")
+		}
+		return nil
+	}
+	fixup := func() error {
+		recorder = append(recorder, "fixup")
+		if len(recorder) < 4 {
+			return errors.New("fixup fail")
+		}
+		connected = true
+		return nil
+	}
+
+	err := ReliableTaskWithPolicy(task, func() bool { return false }, ConstantRetry(time.Millisecond), fixup)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	expected := []string{"task", "fixup", "fixup", "fixup", "task"}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+}
@@ -0,0 +1,110 @@
+package art
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/oklog/ulid/v2"
+)
+
+// routeConstraint restricts which segment can be captured by a wildcard.
+//
+//	"users/{id:int}"                => route param id is int
+//	"users/{id:uuid}"               => route param id is [16]byte
+//	"users/{id:ulid}"               => route param id is ulid.ULID
+//	"orders/{code:[A-Z]{3}-\d+}"    => route param code is string
+//
+// The regexp constraint converts to nil value, so the segment is copied by routeParamStore as string.
+type routeConstraint struct {
+	pattern string
+	convert func(segment string) (value any, ok bool)
+}
+
+var typedRouteConstraints = map[string]func(segment string) (value any, ok bool){
+	"int": func(segment string) (value any, ok bool) {
+		n, err := strconv.Atoi(segment)
+		if err != nil {
+			return nil, false
+		}
+		return n, true
+	},
+	"uuid": func(segment string) (value any, ok bool) {
+		return parseUUID(segment)
+	},
+	"ulid": func(segment string) (value any, ok bool) {
+		id, err := ulid.ParseStrict(segment)
+		if err != nil {
+			return nil, false
+		}
+		return id, true
+	},
+}
+
+func newRouteConstraint(pattern string) (*routeConstraint, error) {
+	convert, ok := typedRouteConstraints[pattern]
+	if ok {
+		return &routeConstraint{pattern: pattern, convert: convert}, nil
+	}
+
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid wildcard constraint %q: %w", pattern, err)
+	}
+	return &routeConstraint{
+		pattern: pattern,
+		convert: func(segment string) (value any, ok bool) {
+			if !re.MatchString(segment) {
+				return nil, false
+			}
+			return nil, true
+		},
+	}, nil
+}
+
+// formatRouteParam is the reverse of routeConstraint.convert
+func formatRouteParam(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case [16]byte:
+		return formatUUID(v)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func formatUUID(id [16]byte) string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], id[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], id[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], id[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], id[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], id[10:])
+	return string(buf)
+}
+
+// parseUUID accept the canonical form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+func parseUUID(segment string) (value any, ok bool) {
+	if len(segment) != 36 {
+		return nil, false
+	}
+	if segment[8] != '-' || segment[13] != '-' || segment[18] != '-' || segment[23] != '-' {
+		return nil, false
+	}
+
+	var id [16]byte
+	src := segment[0:8] + segment[9:13] + segment[14:18] + segment[19:23] + segment[24:36]
+	_, err := hex.Decode(id[:], []byte(src))
+	if err != nil {
+		return nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,86 @@
+package art
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// RouteInfo describe a registered handler of Mux.
+type RouteInfo struct {
+	// Subject is the template of subject, e.g. "v1/users/{id}"
+	Subject string `json:"subject"`
+
+	// Handler is the function name of handler
+	Handler string `json:"handler"`
+
+	// Name is the route name of Mux.NamedHandler
+	Name string `json:"name,omitempty"`
+
+	// ParamNames are the keys of route params, e.g. ["id"]
+	ParamNames []string `json:"param_names,omitempty"`
+
+	// IsDefault is true, if the handler is registered by Mux.DefaultHandler
+	IsDefault bool `json:"is_default"`
+
+	// BodyType is the type of Message.Body, if the handler is registered by Mux.HandlerForType
+	BodyType string `json:"body_type,omitempty"`
+
+	// Conditional is true, if the handler is registered with route predicates, e.g. WhenMeta
+	Conditional bool `json:"conditional,omitempty"`
+
+	// GroupPrefix is the subject of the nearest Mux.Group, e.g. "v1/"
+	GroupPrefix string `json:"group_prefix,omitempty"`
+
+	// Middlewares are the function names of middleware chain, which is applied to the handler
+	Middlewares []string `json:"middlewares,omitempty"`
+
+	// Transforms are the function names of Mux.Transform, which are executed before the handler
+	Transforms []string `json:"transforms,omitempty"`
+}
+
+func (info RouteInfo) endpoint() string {
+	if info.IsDefault {
+		return info.Subject + ".*"
+	}
+	if info.BodyType != "" {
+		return info.Subject + ".(" + info.BodyType + ")"
+	}
+	return info.Subject
+}
+
+type Routes []RouteInfo
+
+func (routes Routes) JSON() ([]byte, error) {
+	return json.MarshalIndent(routes, "", "  ")
+}
+
+// Markdown export routes as a markdown table
+func (routes Routes) Markdown() string {
+	buf := &strings.Builder{}
+	buf.WriteString("| Subject | Name | Handler | Params | Default | Body Type | Conditional | Group | Middlewares | Transforms |\n")
+	buf.WriteString("|---|---|---|---|---|---|---|---|---|---|\n")
+
+	cell := func(values ...string) string {
+		text := strings.Join(values, "<br>")
+		return strings.ReplaceAll(text, "|", `\|`)
+	}
+
+	for _, route := range routes {
+		buf.WriteString("| ")
+		buf.WriteString(strings.Join([]string{
+			cell("`" + route.Subject + "`"),
+			cell(route.Name),
+			cell(route.Handler),
+			cell(route.ParamNames...),
+			cell(strconv.FormatBool(route.IsDefault)),
+			cell(route.BodyType),
+			cell(strconv.FormatBool(route.Conditional)),
+			cell(route.GroupPrefix),
+			cell(route.Middlewares...),
+			cell(route.Transforms...),
+		}, " | "))
+		buf.WriteString(" |\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,90 @@
+package art
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMux_Routes(t *testing.T) {
+	mux := NewMux("/").
+		Middleware(routes_mw()).
+		DefaultHandler(endpoint_default)
+
+	v1 := mux.Group("v1/").
+		Transform(routes_transform)
+	v1.Handler("users/{id}/orders/{order_id:int}", endpoint1, routes_mw())
+
+	expected := Routes{
+		{
+			Subject:     "",
+			Handler:     functionName(endpoint_default),
+			IsDefault:   true,
+			Middlewares: []string{functionName(routes_mw())},
+		},
+		{
+			Subject:     "v1/users/{id}/orders/{order_id:int}",
+			Handler:     functionName(endpoint1),
+			ParamNames:  []string{"id", "order_id"},
+			GroupPrefix: "v1/",
+			Middlewares: []string{functionName(routes_mw()), functionName(routes_mw())},
+			Transforms:  []string{functionName(routes_transform)},
+		},
+	}
+
+	routes := mux.Routes()
+	if !reflect.DeepEqual(routes, expected) {
+		t.Errorf("unexpected output: got %#v, want %#v", routes, expected)
+	}
+
+	data, err := routes.JSON()
+	if err != nil {
+		t.Errorf("unexpected error: got %v", err)
+	}
+	var actual Routes
+	err = json.Unmarshal(data, &actual)
+	if err != nil || !reflect.DeepEqual(actual, expected) {
+		t.Errorf("unexpected json: got %s", data)
+	}
+
+	lines := strings.Split(strings.TrimSpace(routes.Markdown()), "\n")
+	if len(lines) != 4 {
+		t.Errorf("unexpected markdown: got %v", lines)
+	}
+	if !strings.HasPrefix(lines[3], "| `v1/users/{id}/orders/{order_id:int}` | ") {
+		t.Errorf("unexpected markdown: got %v", lines[3])
+	}
+}
+
+func TestRoutes_Markdown(t *testing.T) {
+	routes := Routes{
+		{Subject: "orders", Handler: "handler1", Conditional: true},
+		{Subject: "orders", Handler: "handler2", Name: "orders"},
+		{Subject: "events", Handler: "handler3", BodyType: "*art.Event"},
+		{Subject: "events", Handler: "handler4", BodyType: "[]int"},
+	}
+
+	expected := []string{
+		"| Subject | Name | Handler | Params | Default | Body Type | Conditional | Group | Middlewares | Transforms |",
+		"|---|---|---|---|---|---|---|---|---|---|",
+		"| `orders` |  | handler1 |  | false |  | true |  |  |  |",
+		"| `orders` | orders | handler2 |  | false |  | false |  |  |  |",
+		"| `events` |  | handler3 |  | false | *art.Event | false |  |  |  |",
+		"| `events` |  | handler4 |  | false | []int | false |  |  |  |",
+	}
+	lines := strings.Split(strings.TrimSpace(routes.Markdown()), "\n")
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("unexpected markdown: got\n%v\nwant\n%v", strings.Join(lines, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func routes_transform(_ *Message, _ any) error {
+	return nil
+}
+
+func routes_mw() Middleware {
+	return func(next HandleFunc) HandleFunc {
+		return next
+	}
+}
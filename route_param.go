@@ -0,0 +1,53 @@
+package art
+
+import (
+	"unsafe"
+)
+
+type routeParam struct {
+	key   string
+	value any // converted value of constrained wildcard
+
+	// segment is used when value is nil,
+	// so the string isn't boxed into any while searching.
+	segment string
+}
+
+// routeParamStore is a slice-backed store of route params, it is reused with Message by messagePool.
+//
+// Segments are copied into buf, so they don't refer to Message.Subject,
+// and they are valid until the message is returned by PutMessage.
+type routeParamStore struct {
+	params []routeParam
+	buf    []byte
+}
+
+func (store *routeParamStore) reset() {
+	for i := range store.params {
+		store.params[i] = routeParam{}
+	}
+	store.params = store.params[:0]
+	store.buf = store.buf[:0]
+}
+
+// append copy the segments into buf.
+func (store *routeParamStore) append(params []routeParam) {
+	for _, param := range params {
+		if param.value == nil && param.segment != "" {
+			offset := len(store.buf)
+			store.buf = append(store.buf, param.segment...)
+			param.segment = unsafe.String(&store.buf[offset], len(param.segment))
+		}
+		store.params = append(store.params, param)
+	}
+}
+
+// find search from the end, so the params of the inner mux override the outer mux.
+func (store *routeParamStore) find(key string) (*routeParam, bool) {
+	for i := len(store.params) - 1; i >= 0; i-- {
+		if store.params[i].key == key {
+			return &store.params[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,91 @@
+package art
+
+import (
+	"errors"
+	"reflect"
+)
+
+// When create a route predicate.
+//
+// When it is passed to Mux.Handler, the handler is selected only if predicate returns true,
+// otherwise the plain handler of the same subject is used.
+// Predicates are evaluated inside the route searching without the lock of Mux, see Mux.
+//
+//	mux.Handler("orders", OrdersV2, art.WhenMeta("version", "2")).
+//		Handler("orders", Orders)
+//
+// When it is used as a general middleware, the next handler is skipped if predicate returns false.
+func When(predicate func(message *Message, dep any) bool) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		if next == nil {
+			return func(message *Message, dep any) error {
+				if !predicate(message, dep) {
+					return errRouteMismatch
+				}
+				return nil
+			}
+		}
+
+		return func(message *Message, dep any) error {
+			if !predicate(message, dep) {
+				return nil
+			}
+			return next(message, dep)
+		}
+	}
+}
+
+// WhenMeta create a route predicate which matches Message.Metadata[key] == value.
+func WhenMeta(key string, value string) Middleware {
+	return When(func(message *Message, dep any) bool {
+		return message.Metadata.Str(key) == value
+	})
+}
+
+var errRouteMismatch = errors.New("route predicate mismatch")
+
+// whenPointer is the code pointer of the middleware created by When.
+var whenPointer = reflect.ValueOf(When(nil)).Pointer()
+
+// splitPredicates separate the predicates created by When from middlewares.
+// A predicate is a HandleFunc which returns nil if it is matched.
+func splitPredicates(middlewares []Middleware) (predicates []HandleFunc, others []Middleware) {
+	for _, mw := range middlewares {
+		if reflect.ValueOf(mw).Pointer() == whenPointer {
+			predicates = append(predicates, mw(nil))
+			continue
+		}
+		others = append(others, mw)
+	}
+	return predicates, others
+}
+
+// conditionalHandler is the handler registered with predicates.
+type conditionalHandler struct {
+	predicates             []HandleFunc
+	handler                HandleFunc
+	handlerName            string
+	handlerMiddlewareNames []string
+	handlerChain           []string
+	rawHandler             HandleFunc // without path middlewares
+}
+
+func (c *conditionalHandler) match(search *trieSearch, message *Message, dep any) bool {
+	for _, predicate := range c.predicates {
+		if search.call(predicate, message, dep) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// matchHandler return the first conditional handler whose predicates are matched,
+// otherwise return the plain handler.
+func (search *trieSearch) matchHandler(conditionals []*conditionalHandler, handler HandleFunc, message *Message, dep any) HandleFunc {
+	for _, c := range conditionals {
+		if c.match(search, message, dep) {
+			return c.handler
+		}
+	}
+	return handler
+}
@@ -0,0 +1,168 @@
+package art
+
+import (
+	"strconv"
+	"strings"
+)
+
+// routeSyntax determines how to define wildcards in subject.
+type routeSyntax uint8
+
+const (
+	// braceSyntax
+	//
+	//	"users/{id}", "users/{id:int}", "files/{path...}"
+	braceSyntax routeSyntax = iota
+
+	// mqttSyntax
+	//
+	//	"sensors/+/temp", "sensors/#"
+	//
+	// Route param key is the index of wildcard, e.g. "0", "1".
+	mqttSyntax
+
+	// natsSyntax
+	//
+	//	"orders.*.created", "orders.>"
+	//
+	// Route param key is the index of wildcard, e.g. "0", "1".
+	natsSyntax
+)
+
+// parseWildcard return nil wildcard, if subject[cursor] is not the beginning of wildcard.
+// Otherwise, return the index of the last char of wildcard.
+// If the wildcard is invalid, return RouteError.
+func (node *trie) parseWildcard(subject string, cursor int) (target *wildcard, idx int, err error) {
+	switch node.syntax {
+	case mqttSyntax:
+		return node.parseMqttWildcard(subject, cursor)
+	case natsSyntax:
+		return node.parseNatsWildcard(subject, cursor)
+	default:
+		return node.parseBraceWildcard(subject, cursor)
+	}
+}
+
+const catchAllSuffix = "..."
+
+func (node *trie) parseBraceWildcard(subject string, cursor int) (target *wildcard, idx int, err error) {
+	if subject[cursor] != '{' {
+		return nil, cursor, nil
+	}
+
+	if node.delimiter == "" {
+		return nil, cursor, newRouteError(ErrInvalidRoute, node.fullSubject+subject[cursor:], "route delimiter is empty: not support wildcard")
+	}
+
+	idx = wildcardCloseIndex(subject, cursor)
+	if idx == len(subject) {
+		return nil, cursor, newRouteError(ErrInvalidRoute, node.fullSubject+subject[cursor:], "lack wildcard '}'")
+	}
+
+	word := subject[cursor+1 : idx] // word, exclude {}
+	target = &wildcard{word: word, kind: wildcardPlain}
+
+	if strings.HasSuffix(word, catchAllSuffix) {
+		if idx+1 != len(subject) {
+			return nil, cursor, newRouteError(ErrInvalidRoute, node.fullSubject+subject[cursor:], "catch-all wildcard must be at the end")
+		}
+		target.word = strings.TrimSuffix(word, catchAllSuffix)
+		target.kind = wildcardCatchAll
+
+	} else if i := strings.IndexByte(word, ':'); i >= 0 {
+		constraint, err := newRouteConstraint(word[i+1:])
+		if err != nil {
+			return nil, cursor, newRouteError(ErrInvalidRoute, node.fullSubject+subject[cursor:], "%v", err)
+		}
+		target.word = word[:i]
+		target.kind = wildcardConstrained
+		target.constraint = constraint
+	}
+
+	return target, idx, nil
+}
+
+// wildcardCloseIndex return the index of '}' which closes the '{' at cursor.
+// Braces inside a constraint are allowed, e.g. {code:[A-Z]{3}}
+func wildcardCloseIndex(subject string, cursor int) int {
+	depth := 0
+	for idx := cursor; idx < len(subject); idx++ {
+		switch subject[idx] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return idx
+			}
+		}
+	}
+	return len(subject)
+}
+
+// https://docs.oasis-open.org/mqtt/mqtt/v5.0/os/mqtt-v5.0-os.html#_Toc3901241
+func (node *trie) parseMqttWildcard(subject string, cursor int) (target *wildcard, idx int, err error) {
+	char := subject[cursor]
+	if char != '+' && char != '#' {
+		return nil, cursor, nil
+	}
+
+	isLevelStart := node.isSegmentStart(subject, cursor)
+	isLevelFinish := cursor+1 == len(subject) || subject[cursor+1] == node.delimiter[0]
+	if !isLevelStart || !isLevelFinish {
+		return nil, cursor, newRouteError(ErrInvalidRoute, node.fullSubject+subject[cursor:], "wildcard %q must occupy an entire level", char)
+	}
+
+	target = &wildcard{
+		word:          strconv.Itoa(node.wildcardQty),
+		kind:          wildcardPlain,
+		excludeDollar: node.fullSubject == "" && cursor == 0,
+	}
+
+	if char == '#' {
+		if cursor+1 != len(subject) {
+			return nil, cursor, newRouteError(ErrInvalidRoute, node.fullSubject+subject[cursor:], "wildcard '#' must be the last level")
+		}
+		target.kind = wildcardCatchAll
+		target.matchParent = node.fullSubject+subject[:cursor] != ""
+	}
+
+	return target, cursor, nil
+}
+
+// https://docs.nats.io/nats-concepts/subjects#wildcards
+func (node *trie) parseNatsWildcard(subject string, cursor int) (target *wildcard, idx int, err error) {
+	char := subject[cursor]
+	if char != '*' && char != '>' {
+		return nil, cursor, nil
+	}
+
+	isTokenStart := node.isSegmentStart(subject, cursor)
+	isTokenFinish := cursor+1 == len(subject) || subject[cursor+1] == node.delimiter[0]
+	if !isTokenStart || !isTokenFinish {
+		return nil, cursor, newRouteError(ErrInvalidRoute, node.fullSubject+subject[cursor:], "wildcard %q must occupy an entire token", char)
+	}
+
+	target = &wildcard{
+		word:     strconv.Itoa(node.wildcardQty),
+		kind:     wildcardPlain,
+		nonEmpty: true,
+	}
+
+	if char == '>' {
+		if cursor+1 != len(subject) {
+			return nil, cursor, newRouteError(ErrInvalidRoute, node.fullSubject+subject[cursor:], "wildcard '>' must be the last token")
+		}
+		target.kind = wildcardCatchAll
+	}
+
+	return target, cursor, nil
+}
+
+func (node *trie) isSegmentStart(subject string, cursor int) bool {
+	if cursor > 0 {
+		return subject[cursor-1] == node.delimiter[0]
+	}
+	n := len(node.fullSubject)
+	return n == 0 || node.fullSubject[n-1] == node.delimiter[0]
+}
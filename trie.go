@@ -6,11 +6,13 @@ import (
 	"reflect"
 	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
 // middleware 只會用在 handler 和 defaultHandler
-// handle message 執行順序, 依照號碼 0~3
+// handle message 執行順序, 依照號碼 0~4
 type paramHandler struct {
 	// any
 	middlewares []Middleware
@@ -19,14 +21,22 @@ type paramHandler struct {
 	transform HandleFunc
 
 	// 1
-	handler     HandleFunc
-	handlerName string
+	handler                HandleFunc
+	handlerName            string
+	handlerMiddlewareNames []string     // route middlewares, e.g. Mux.Handler(subject, h, mw...)
+	rawHandler             HandleFunc   // without path middlewares
+	predicates             []HandleFunc // if not empty, handler is registered as conditionalHandler
 
 	// 2
-	defaultHandler     HandleFunc
-	defaultHandlerName string
+	typeHandler *typeHandler
 
 	// 3
+	defaultHandler                HandleFunc
+	defaultHandlerName            string
+	defaultHandlerMiddlewareNames []string
+	rawDefaultHandler             HandleFunc // without path middlewares
+
+	// 4
 	notFoundHandler HandleFunc
 }
 
@@ -37,16 +47,35 @@ func (param *paramHandler) register(leafNode *trie, path []Middleware) error {
 
 	if param.transform != nil {
 		if leafNode.transform != nil {
-			return errors.New("assign duplicated transform")
+			return newRouteError(ErrDuplicatedRoute, leafNode.fullSubject, "assign duplicated transform")
 		}
 		leafNode.transform = param.transform
 	}
 
+	if param.handler != nil && param.predicates != nil {
+		conditional := &conditionalHandler{
+			predicates:             param.predicates,
+			handler:                Link(param.handler, path...),
+			handlerName:            param.handlerName,
+			handlerMiddlewareNames: param.handlerMiddlewareNames,
+			handlerChain:           middlewareChain(path, param.handlerMiddlewareNames),
+			rawHandler:             param.handler,
+		}
+		if conditional.handlerName == "" {
+			conditional.handlerName = functionName(param.handler)
+		}
+		leafNode.conditionalHandlers = append(leafNode.conditionalHandlers, conditional)
+		return nil
+	}
+
 	if param.handler != nil {
 		if leafNode.handler != nil {
-			return errors.New("assign duplicated handler")
+			return newRouteError(ErrDuplicatedRoute, leafNode.fullSubject, "assign duplicated handler: registered by %v", leafNode.handlerName)
 		}
+		leafNode.rawHandler = param.handler
 		leafNode.handler = Link(param.handler, path...)
+		leafNode.handlerMiddlewareNames = param.handlerMiddlewareNames
+		leafNode.handlerChain = middlewareChain(path, param.handlerMiddlewareNames)
 
 		if param.handlerName == "" {
 			leafNode.handlerName = functionName(param.handler)
@@ -55,11 +84,23 @@ func (param *paramHandler) register(leafNode *trie, path []Middleware) error {
 		}
 	}
 
+	if param.typeHandler != nil {
+		h := *param.typeHandler
+		h.handler = Link(h.rawHandler, path...)
+		h.handlerChain = middlewareChain(path, h.handlerMiddlewareNames)
+		if !leafNode.typeHandlers.add(&h) {
+			return newRouteError(ErrDuplicatedRoute, leafNode.fullSubject, "assign duplicated handler for type %v", h.bodyType)
+		}
+	}
+
 	if param.defaultHandler != nil {
 		if leafNode.defaultHandler != nil {
-			return errors.New("assign duplicated defaultHandler")
+			return newRouteError(ErrDuplicatedRoute, leafNode.fullSubject, "assign duplicated defaultHandler: registered by %v", leafNode.defaultHandlerName)
 		}
+		leafNode.rawDefaultHandler = param.defaultHandler
 		leafNode.defaultHandler = Link(param.defaultHandler, path...)
+		leafNode.defaultHandlerMiddlewareNames = param.defaultHandlerMiddlewareNames
+		leafNode.defaultHandlerChain = middlewareChain(path, param.defaultHandlerMiddlewareNames)
 
 		if param.defaultHandlerName == "" {
 			leafNode.defaultHandlerName = functionName(param.defaultHandler)
@@ -70,7 +111,7 @@ func (param *paramHandler) register(leafNode *trie, path []Middleware) error {
 
 	if param.notFoundHandler != nil {
 		if leafNode.notFoundHandler != nil {
-			return errors.New("assign duplicated notFoundHandler")
+			return newRouteError(ErrDuplicatedRoute, leafNode.fullSubject, "assign duplicated notFoundHandler")
 		}
 		leafNode.notFoundHandler = param.notFoundHandler
 	}
@@ -82,6 +123,19 @@ func functionName(fn any) string {
 	return runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
 }
 
+func middlewareNames(middlewares []Middleware) []string {
+	names := make([]string, 0, len(middlewares))
+	for _, mw := range middlewares {
+		names = append(names, functionName(mw))
+	}
+	return names
+}
+
+// middlewareChain return the names of middlewares which are applied to the handler
+func middlewareChain(path []Middleware, routeMiddlewareNames []string) []string {
+	return append(middlewareNames(path), routeMiddlewareNames...)
+}
+
 func newTrie(delimiter string) *trie {
 	return &trie{
 		staticChild: make(map[byte]*trie),
@@ -89,19 +143,115 @@ func newTrie(delimiter string) *trie {
 	}
 }
 
+func (node *trie) newChild(fullSubject string) *trie {
+	return &trie{
+		staticChild: make(map[byte]*trie),
+		delimiter:   node.delimiter,
+		syntax:      node.syntax,
+		fullSubject: fullSubject,
+		lazy:        node.lazy,
+		wildcardQty: node.wildcardQty,
+	}
+}
+
 type trie struct {
 	staticChild map[byte]*trie // key : value => char : child
 
-	wildcardChildWord string
-	wildcardChild     *trie
+	// wildcardChild is sorted by priority: constrained > plain > catch-all,
+	// the same priority is sorted by registration order.
+	wildcardChild []*trie
+	wildcard      *wildcard // not nil, if node is wildcard child
+	wildcardQty   int       // number of wildcards from root to node
+	isGroup       bool      // group node can't be deleted, because Mux.Group refers to it
+	routeName     string    // route name of handler, it is used by Mux.BuildSubject
+
+	// conditionalHandlers are selected before handler, if their predicates are matched
+	conditionalHandlers []*conditionalHandler
+
+	// typeHandlers are selected by the type of Message.Body, when no handler is found
+	typeHandlers typeRegistry
+
+	// numberChild is used by Mux.HandleNumber,
+	// key : value => number : the static nodes from node to the child of "number/", the last one is the child.
+	numberChild map[int][]*trie
+
+	handlerChain        []string // names of middlewares which are applied to handler
+	defaultHandlerChain []string // names of middlewares which are applied to defaultHandler
 
 	delimiter   string
+	syntax      routeSyntax
+	lazy        bool // lazy middleware: path middlewares are linked by resolveMiddleware
 	fullSubject string
 	paramHandler
 }
 
-func (node *trie) addRoute(subject string, cursor int, param *paramHandler, path []Middleware) *trie {
-	if node.middlewares != nil {
+type wildcardKind uint8
+
+const (
+	wildcardConstrained wildcardKind = iota // {word:constraint}
+	wildcardPlain                           // {word}
+	wildcardCatchAll                        // {word...}
+)
+
+type wildcard struct {
+	word       string
+	kind       wildcardKind
+	constraint *routeConstraint
+
+	// matchParent is used by mqtt '#', "sport/#" also matches "sport"
+	matchParent bool
+
+	// excludeDollar is used by mqtt, the first level wildcard doesn't match subject beginning with '$'
+	excludeDollar bool
+
+	// nonEmpty is used by nats, wildcard doesn't match empty token
+	nonEmpty bool
+}
+
+// validate check whether segment can be matched by wildcard
+func (w *wildcard) validate(segment string, isFirst bool, delimiter string) error {
+	if w.nonEmpty && segment == "" {
+		return errors.New("must not be empty")
+	}
+	if w.excludeDollar && isFirst && strings.HasPrefix(segment, "$") {
+		return errors.New("must not begin with '$'")
+	}
+	if w.kind != wildcardCatchAll && strings.Contains(segment, delimiter) {
+		return fmt.Errorf("must not contain delimiter %q", delimiter)
+	}
+	if w.kind == wildcardConstrained {
+		if _, ok := w.constraint.convert(segment); !ok {
+			return fmt.Errorf("doesn't satisfy constraint %q", w.constraint.pattern)
+		}
+	}
+	return nil
+}
+
+// shape ignore the word of wildcard, two wildcards with the same shape match the same segments.
+func (w *wildcard) shape() string {
+	switch w.kind {
+	case wildcardConstrained:
+		return "{:" + w.constraint.pattern + "}"
+	case wildcardCatchAll:
+		return "{...}"
+	default:
+		return "{}"
+	}
+}
+
+func (w *wildcard) equal(other *wildcard) bool {
+	if w.word != other.word || w.kind != other.kind {
+		return false
+	}
+	if w.kind == wildcardConstrained {
+		return w.constraint.pattern == other.constraint.pattern
+	}
+	return true
+}
+
+// addRoute return RouteError, if the subject is invalid or conflicts with the registered routes.
+func (node *trie) addRoute(subject string, cursor int, param *paramHandler, path []Middleware) (*trie, error) {
+	if node.middlewares != nil && !node.lazy {
 		path = append(path, node.middlewares...)
 	}
 
@@ -110,165 +260,774 @@ func (node *trie) addRoute(subject string, cursor int, param *paramHandler, path
 			param = &paramHandler{
 				middlewares: path,
 			}
+			node.isGroup = true
 		}
 
 		leafNode := node
 		err := param.register(leafNode, path)
 		if err != nil {
-			Err := fmt.Errorf("subject=%q: %w", subject, err)
-			panic(Err)
+			return nil, err
 		}
-		return leafNode
+		return leafNode, nil
+	}
+
+	if node.wildcard != nil && node.wildcard.kind == wildcardCatchAll {
+		return nil, newRouteError(ErrInvalidRoute, node.fullSubject+subject[cursor:], "catch-all wildcard must be at the end")
 	}
 
-	char := subject[cursor]
-	if char != '{' {
+	target, idx, err := node.parseWildcard(subject, cursor)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		char := subject[cursor]
 		child, exist := node.staticChild[char]
 		if !exist {
-			child = newTrie(node.delimiter)
-			child.fullSubject = node.fullSubject + string(char)
+			child = node.newChild(node.fullSubject + string(char))
 			node.staticChild[char] = child
 		}
 		return child.addRoute(subject, cursor+1, param, path)
 	}
 
-	if node.delimiter == "" {
-		err := fmt.Errorf("subject=%q: route delimiter is empty: not support wildcard", subject)
-		panic(err)
+	for _, child := range node.wildcardChild {
+		if child.wildcard.equal(target) {
+			return child.addRoute(subject, idx+1, param, path)
+		}
 	}
 
-	idx := cursor
-	for idx < len(subject) && subject[idx] != '}' {
-		idx++
+	child := node.newChild(node.fullSubject + subject[cursor:idx+1]) // {word}, include {}
+	child.wildcard = target
+	child.wildcardQty++
+	node.insertWildcardChild(child)
+
+	return child.addRoute(subject, idx+1, param, path)
+}
+
+// insertWildcardChild keep priority: insert after the last child whose kind <= target kind
+func (node *trie) insertWildcardChild(child *trie) {
+	pos := len(node.wildcardChild)
+	for pos > 0 && node.wildcardChild[pos-1].wildcard.kind > child.wildcard.kind {
+		pos--
 	}
+	node.wildcardChild = append(node.wildcardChild, nil)
+	copy(node.wildcardChild[pos+1:], node.wildcardChild[pos:])
+	node.wildcardChild[pos] = child
+}
 
-	if subject[idx] != '}' {
-		err := fmt.Errorf("subject=%q: lack wildcard '}'", subject)
-		panic(err)
+// graft copy the subtree of src into node, it is used by Mux.Mount.
+// The handlers of src have been linked with src middlewares, and they will be linked with path again.
+// The route names of src are registered in routeNames with the grafted subject.
+func (node *trie) graft(src *trie, path []Middleware, wrap Middleware, routeNames map[string]string) error {
+	if src.isGroup {
+		node.isGroup = true
 	}
 
-	if node.wildcardChild != nil {
-		if node.wildcardChildWord != subject[cursor+1:idx] {
-			err := fmt.Errorf("subject=%q: assign duplicated wildcard: %q", node.wildcardChild.fullSubject, subject)
-			panic(err)
+	if src.routeName != "" {
+		if template, exist := routeNames[src.routeName]; exist {
+			return newRouteError(ErrDuplicatedRoute, node.fullSubject, "assign duplicated route name %q: registered by %q", src.routeName, template)
 		}
-		return node.wildcardChild.addRoute(subject, idx+1, param, path)
+		node.routeName = src.routeName
+		routeNames[src.routeName] = node.fullSubject
 	}
 
-	child := newTrie(node.delimiter)
-	child.fullSubject = node.fullSubject + subject[cursor:idx+1] // {word}, include {}
-	node.wildcardChildWord = subject[cursor+1 : idx]             // word, exclude {}
-	node.wildcardChild = child
-	return child.addRoute(subject, idx+1, param, path)
+	if src.transform != nil {
+		if node.transform != nil {
+			return newRouteError(ErrDuplicatedRoute, node.fullSubject, "assign duplicated transform")
+		}
+		node.transform = src.transform
+	}
+
+	if src.handler != nil {
+		if node.handler != nil {
+			return newRouteError(ErrDuplicatedRoute, node.fullSubject, "assign duplicated handler: registered by %v", node.handlerName)
+		}
+		node.rawHandler = wrap(src.handler)
+		node.handler = Link(node.rawHandler, path...)
+		node.handlerName = src.handlerName
+		node.handlerMiddlewareNames = src.handlerChain
+		node.handlerChain = middlewareChain(path, src.handlerChain)
+	}
+
+	for _, c := range src.conditionalHandlers {
+		rawHandler := wrap(c.handler)
+		node.conditionalHandlers = append(node.conditionalHandlers, &conditionalHandler{
+			predicates:             c.predicates,
+			handler:                Link(rawHandler, path...),
+			handlerName:            c.handlerName,
+			handlerMiddlewareNames: c.handlerChain,
+			handlerChain:           middlewareChain(path, c.handlerChain),
+			rawHandler:             rawHandler,
+		})
+	}
+
+	var err error
+	src.typeHandlers.each(func(h *typeHandler) {
+		rawHandler := wrap(h.handler)
+		added := node.typeHandlers.add(&typeHandler{
+			bodyType:               h.bodyType,
+			handler:                Link(rawHandler, path...),
+			handlerName:            h.handlerName,
+			handlerMiddlewareNames: h.handlerChain,
+			handlerChain:           middlewareChain(path, h.handlerChain),
+			rawHandler:             rawHandler,
+		})
+		if !added && err == nil {
+			err = newRouteError(ErrDuplicatedRoute, node.fullSubject, "assign duplicated handler for type %v", h.bodyType)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if src.defaultHandler != nil {
+		if node.defaultHandler != nil {
+			return newRouteError(ErrDuplicatedRoute, node.fullSubject, "assign duplicated defaultHandler: registered by %v", node.defaultHandlerName)
+		}
+		node.rawDefaultHandler = wrap(src.defaultHandler)
+		node.defaultHandler = Link(node.rawDefaultHandler, path...)
+		node.defaultHandlerName = src.defaultHandlerName
+		node.defaultHandlerMiddlewareNames = src.defaultHandlerChain
+		node.defaultHandlerChain = middlewareChain(path, src.defaultHandlerChain)
+	}
+
+	if src.notFoundHandler != nil {
+		if node.notFoundHandler != nil {
+			return newRouteError(ErrDuplicatedRoute, node.fullSubject, "assign duplicated notFoundHandler")
+		}
+		node.notFoundHandler = wrap(src.notFoundHandler)
+	}
+
+	for char, srcChild := range src.staticChild {
+		child, exist := node.staticChild[char]
+		if !exist {
+			child = node.newChild(node.fullSubject + string(char))
+			node.staticChild[char] = child
+		}
+		err := child.graft(srcChild, path, wrap, routeNames)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, srcChild := range src.wildcardChild {
+		var child *trie
+		for _, next := range node.wildcardChild {
+			if next.wildcard.equal(srcChild.wildcard) {
+				child = next
+				break
+			}
+		}
+
+		if child == nil {
+			child = node.newChild(node.fullSubject + srcChild.fullSubject[len(src.fullSubject):])
+			child.wildcard = srcChild.wildcard
+			child.wildcardQty++
+			node.insertWildcardChild(child)
+		}
+
+		err := child.graft(srcChild, path, wrap, routeNames)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trieSearch record the state of depth-first search.
+// When a branch can't find handler, the search backtracks to the previous wildcard.
+type trieSearch struct {
+	params []routeParam
+
+	// shared is the lock of Mux, it is nil for CompiledMux.
+	shared *muxShared
+
+	// The deepest typeHandler, defaultHandler and notFoundHandler are used when no handler is found.
+	typeHandler     HandleFunc
+	typeParams      []routeParam
+	typeDepth       int
+	defaultHandler  HandleFunc
+	defaultParams   []routeParam
+	defaultDepth    int
+	notFoundHandler HandleFunc
+	notFoundDepth   int
+}
+
+func (search *trieSearch) reset() {
+	search.params = search.params[:0]
+	search.shared = nil
+	search.typeHandler = nil
+	search.typeParams = search.typeParams[:0]
+	search.typeDepth = -1
+	search.defaultHandler = nil
+	search.defaultParams = search.defaultParams[:0]
+	search.defaultDepth = -1
+	search.notFoundHandler = nil
+	search.notFoundDepth = -1
+}
+
+// call execute the callback of user, e.g. Transform and route predicate, without the read lock of Mux,
+// so the callback can use the same mux, and waiting registration won't deadlock with the nested read lock.
+// If the callback panics, the lock has been released.
+func (search *trieSearch) call(fn HandleFunc, message *Message, dep any) error {
+	if search.shared == nil {
+		return fn(message, dep)
+	}
+	search.shared.RUnlock()
+	err := fn(message, dep)
+	search.shared.RLock()
+	return err
 }
 
-func (node *trie) handleMessage(subject string, cursor int, message *Message, dep any) error {
-	current := node
+var trieSearchPool = newPool(func() *trieSearch {
+	return &trieSearch{
+		params:        make([]routeParam, 0, 4),
+		typeParams:    make([]routeParam, 0, 4),
+		defaultParams: make([]routeParam, 0, 4),
+	}
+})
+
+// addNumberRoute record the route of "number/" which has been registered into node.
+func (node *trie) addNumberRoute(number int) {
+	subject := strconv.Itoa(number) + node.delimiter
+
+	path := make([]*trie, 0, len(subject))
+	cursor := node
+	for i := 0; i < len(subject); i++ {
+		child, exist := cursor.staticChild[subject[i]]
+		if !exist {
+			return
+		}
+		path = append(path, child)
+		cursor = child
+	}
+
+	if node.numberChild == nil {
+		node.numberChild = make(map[int][]*trie)
+	}
+	node.numberChild[number] = path
+}
 
-	var defaultHandler, notFoundHandler HandleFunc
+func (node *trie) removeNumberRoute(number int) {
+	path, exist := node.numberChild[number]
+	if !exist {
+		return
+	}
+	if !path[len(path)-1].isGroup {
+		delete(node.numberChild, number)
+	}
+}
 
-	const notWildcard = -1
-	wildcardStart := notWildcard
-	var wildcardParent *trie
+// findNumberHandler return false, if the handler isn't registered by number, or there is a transform on the path.
+// The returned subject is relative to node.
+func (node *trie) findNumberHandler(numbers []int) (handler HandleFunc, subject string, ok bool) {
+	if node.transform != nil || len(numbers) == 0 {
+		return nil, "", false
+	}
 
-	for cursor <= len(subject) {
-		if current.transform != nil {
-			err := current.transform(message, dep)
-			if err != nil {
-				return err
+	child := node
+	for _, number := range numbers {
+		path, exist := child.numberChild[number]
+		if !exist {
+			return nil, "", false
+		}
+		for _, next := range path {
+			if next.transform != nil {
+				return nil, "", false
 			}
-			subject = message.Subject
+		}
+		child = path[len(path)-1]
+	}
+
+	if child.handler == nil || child.conditionalHandlers != nil {
+		return nil, "", false
+	}
+	return child.handler, child.fullSubject[len(node.fullSubject):], true
+}
+
+// removeRoute return true, if node has nothing and can be deleted by parent.
+func (node *trie) removeRoute(subject string, cursor int) (isEmpty bool, err error) {
+	if len(subject) == cursor {
+		if node.handler == nil && node.conditionalHandlers == nil {
+			return false, ErrorWrapWithMessage(ErrNotFoundSubject, "subject=%q", subject)
+		}
+		node.conditionalHandlers = nil
+		node.handler = nil
+		node.handlerName = ""
+		node.rawHandler = nil
+		node.routeName = ""
+		node.handlerMiddlewareNames = nil
+		node.handlerChain = nil
+		return node.isEmpty(), nil
+	}
+
+	target, idx, err := node.parseWildcard(subject, cursor)
+	if err != nil {
+		return false, err
+	}
+	if target == nil {
+		char := subject[cursor]
+		child, exist := node.staticChild[char]
+		if !exist {
+			return false, ErrorWrapWithMessage(ErrNotFoundSubject, "subject=%q", subject)
 		}
 
-		if current.defaultHandler != nil {
-			defaultHandler = current.defaultHandler
+		childIsEmpty, err := child.removeRoute(subject, cursor+1)
+		if err != nil {
+			return false, err
 		}
+		if childIsEmpty {
+			delete(node.staticChild, char)
+		}
+		return node.isEmpty(), nil
+	}
 
-		if current.notFoundHandler != nil {
-			notFoundHandler = current.notFoundHandler
+	for i, child := range node.wildcardChild {
+		if !child.wildcard.equal(target) {
+			continue
 		}
 
-		if current.wildcardChild != nil {
-			wildcardStart = cursor
-			wildcardParent = current
+		childIsEmpty, err := child.removeRoute(subject, idx+1)
+		if err != nil {
+			return false, err
+		}
+		if childIsEmpty {
+			node.wildcardChild = append(node.wildcardChild[:i:i], node.wildcardChild[i+1:]...)
 		}
+		return node.isEmpty(), nil
+	}
+	return false, ErrorWrapWithMessage(ErrNotFoundSubject, "subject=%q", subject)
+}
 
-		if cursor == len(subject) {
-			break
+// buildSubject write the concrete subject into buf according to template and params,
+// return the keys of used params.
+func (node *trie) buildSubject(template string, cursor int, params map[string]any, buf []byte) ([]byte, []string, error) {
+	if len(template) == cursor {
+		if node.handler == nil && node.conditionalHandlers == nil {
+			return nil, nil, ErrorWrapWithMessage(ErrNotFoundSubject, "template=%q: not found handler", template)
 		}
+		return buf, nil, nil
+	}
 
-		child, exist := current.staticChild[subject[cursor]]
+	target, idx, err := node.parseWildcard(template, cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+	if target == nil {
+		child, exist := node.staticChild[template[cursor]]
 		if !exist {
+			return nil, nil, ErrorWrapWithMessage(ErrNotFoundSubject, "template=%q", template)
+		}
+		return child.buildSubject(template, cursor+1, params, append(buf, template[cursor]))
+	}
+
+	var child *trie
+	for _, next := range node.wildcardChild {
+		if next.wildcard.equal(target) {
+			child = next
 			break
 		}
-		cursor++
-		current = child
+	}
+	if child == nil {
+		return nil, nil, ErrorWrapWithMessage(ErrNotFoundSubject, "template=%q", template)
 	}
 
-	// for static route
-	if current.handler != nil {
-		return current.handler(message, dep)
+	value, ok := params[target.word]
+	if !ok {
+		return nil, nil, ErrorWrapWithMessage(ErrInvalidRouteParam, "template=%q: param %q is missing", template, target.word)
 	}
-	if wildcardParent == nil {
-		if defaultHandler != nil {
-			return defaultHandler(message, dep)
+	segment := formatRouteParam(value)
+
+	err = child.wildcard.validate(segment, len(buf) == 0, node.delimiter)
+	if err != nil {
+		return nil, nil, ErrorWrapWithMessage(ErrInvalidRouteParam, "template=%q: param %q=%q %v", template, target.word, segment, err)
+	}
+
+	if segment == "" && child.wildcard.matchParent {
+		buf = buf[:len(buf)-1] // "sport/#" => "sport"
+	}
+
+	buf, used, err := child.buildSubject(template, idx+1, params, append(buf, segment...))
+	return buf, append(used, target.word), err
+}
+
+func (node *trie) isEmpty() bool {
+	return !node.isGroup &&
+		node.handler == nil &&
+		node.conditionalHandlers == nil &&
+		node.typeHandlers.isEmpty() &&
+		node.defaultHandler == nil &&
+		node.notFoundHandler == nil &&
+		node.transform == nil &&
+		node.middlewares == nil &&
+		len(node.staticChild) == 0 &&
+		len(node.wildcardChild) == 0
+}
+
+// hasHandlerOrSubGroup return true, if there is a handler on node or its descendants, or a group on its descendants.
+// In eager middleware mode, the middleware registered on node won't be applied to them.
+func (node *trie) hasHandlerOrSubGroup() bool {
+	if node.handler != nil || node.conditionalHandlers != nil || !node.typeHandlers.isEmpty() || node.defaultHandler != nil {
+		return true
+	}
+
+	for _, child := range node.staticChild {
+		if child.isGroup || child.hasHandlerOrSubGroup() {
+			return true
 		}
-		if notFoundHandler != nil {
-			return notFoundHandler(message, dep)
+	}
+	for _, child := range node.wildcardChild {
+		if child.isGroup || child.hasHandlerOrSubGroup() {
+			return true
+		}
+	}
+	return false
+}
+
+// shadowedRoutes find the handlers which can never be matched,
+// because a sibling wildcard with higher priority has the same shape.
+func (node *trie) shadowedRoutes() []*RouteError {
+	var errs []*RouteError
+	for i, child := range node.wildcardChild {
+		shapes := make(map[string]string)
+		child.routeShapes("", shapes)
+
+		for _, prior := range node.wildcardChild[:i] {
+			if prior.wildcard.shape() != child.wildcard.shape() {
+				continue
+			}
+
+			priorShapes := make(map[string]string)
+			prior.routeShapes("", priorShapes)
+			for shape, subject := range shapes {
+				if priorSubject, ok := priorShapes[shape]; ok {
+					errs = append(errs, newRouteError(ErrDuplicatedRoute, subject, "shadowed by %q", priorSubject))
+					delete(shapes, shape)
+				}
+			}
+		}
+	}
+
+	for _, child := range node.staticChild {
+		errs = append(errs, child.shadowedRoutes()...)
+	}
+	for _, child := range node.wildcardChild {
+		errs = append(errs, child.shadowedRoutes()...)
+	}
+	return errs
+}
+
+// routeShapes collect the handlers of node and its descendants,
+// key : value => subject template without wildcard names : full subject
+func (node *trie) routeShapes(prefix string, shapes map[string]string) {
+	if node.handler != nil {
+		shapes[prefix] = node.fullSubject
+	}
+	for char, child := range node.staticChild {
+		child.routeShapes(prefix+string(char), shapes)
+	}
+	for _, child := range node.wildcardChild {
+		child.routeShapes(prefix+child.wildcard.shape(), shapes)
+	}
+}
+
+// resolveMiddleware link the middlewares from root to node, it is used by lazy middleware mode.
+func (node *trie) resolveMiddleware(path []Middleware) {
+	if node.middlewares != nil {
+		path = append(path[:len(path):len(path)], node.middlewares...)
+	}
+
+	if node.rawHandler != nil {
+		node.handler = Link(node.rawHandler, path...)
+		node.handlerChain = middlewareChain(path, node.handlerMiddlewareNames)
+	}
+	for _, c := range node.conditionalHandlers {
+		c.handler = Link(c.rawHandler, path...)
+		c.handlerChain = middlewareChain(path, c.handlerMiddlewareNames)
+	}
+	node.typeHandlers.each(func(h *typeHandler) {
+		h.handler = Link(h.rawHandler, path...)
+		h.handlerChain = middlewareChain(path, h.handlerMiddlewareNames)
+	})
+	if node.rawDefaultHandler != nil {
+		node.defaultHandler = Link(node.rawDefaultHandler, path...)
+		node.defaultHandlerChain = middlewareChain(path, node.defaultHandlerMiddlewareNames)
+	}
+
+	for _, child := range node.staticChild {
+		child.resolveMiddleware(path)
+	}
+	for _, child := range node.wildcardChild {
+		child.resolveMiddleware(path)
+	}
+}
+
+// findHandler return ErrNotFoundSubject, if there is no handler, defaultHandler and notFoundHandler.
+// The returned params are owned by search.
+func (node *trie) findHandler(subject string, cursor int, search *trieSearch, message *Message, dep any) (HandleFunc, []routeParam, error) {
+	handler, err := node.search(subject, cursor, search, message, dep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if handler != nil {
+		return handler, search.params, nil
+	}
+	if search.typeHandler != nil {
+		return search.typeHandler, search.typeParams, nil
+	}
+	if search.defaultHandler != nil {
+		return search.defaultHandler, search.defaultParams, nil
+	}
+	if search.notFoundHandler != nil {
+		return search.notFoundHandler, nil, nil
+	}
+	return nil, nil, ErrNotFoundSubject
+}
+
+// search return nil handler, if not found.
+//
+// Priority: static > constrained wildcard > plain wildcard > catch-all wildcard.
+// Transform is executed when the search arrives at the node, and the message subject is replaced.
+func (node *trie) search(subject string, cursor int, search *trieSearch, message *Message, dep any) (HandleFunc, error) {
+	subject, err := search.arrive(subject, cursor, message, dep, node.transform, &node.typeHandlers, node.defaultHandler, node.notFoundHandler)
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor == len(subject) {
+		if handler := search.matchHandler(node.conditionalHandlers, node.handler, message, dep); handler != nil {
+			return handler, nil
+		}
+		if node.delimiter != "" {
+			if child, exist := node.staticChild[node.delimiter[0]]; exist {
+				handler, err := searchParentMatcher(child.wildcardChild, subject, cursor, search, message, dep)
+				if handler != nil || err != nil {
+					return handler, err
+				}
+			}
+		}
+	}
+
+	// for static route
+	if cursor < len(subject) {
+		child, exist := node.staticChild[subject[cursor]]
+		if exist {
+			handler, err := child.search(subject, cursor+1, search, message, dep)
+			if handler != nil || err != nil {
+				return handler, err
+			}
 		}
-		return ErrNotFoundSubject
 	}
 
 	// for wildcard route
-	wildcardFinish := wildcardStart
-	for wildcardFinish < len(subject) && subject[wildcardFinish] != current.delimiter[0] {
-		wildcardFinish++
+	return searchWildcardChild(node.wildcardChild, node.delimiter, subject, cursor, search, message, dep)
+}
+
+// searchNode is implemented by trie and radixNode, so they share the search steps and have the same semantics.
+type searchNode interface {
+	*trie | *radixNode
+	wildcardOf() *wildcard
+	search(subject string, cursor int, search *trieSearch, message *Message, dep any) (HandleFunc, error)
+}
+
+func (node *trie) wildcardOf() *wildcard { return node.wildcard }
+
+// arrive is executed when the search arrives at a node,
+// it executes transform, and records the deepest typeHandler, defaultHandler and notFoundHandler.
+// The subject replaced by transform is returned.
+func (search *trieSearch) arrive(subject string, cursor int, message *Message, dep any, transform HandleFunc, typeHandlers *typeRegistry, defaultHandler, notFoundHandler HandleFunc) (string, error) {
+	if transform != nil {
+		err := search.call(transform, message, dep)
+		if err != nil {
+			return "", err
+		}
+		subject = message.Subject
+	}
+
+	if !typeHandlers.isEmpty() && cursor > search.typeDepth {
+		if h := typeHandlers.find(message.Body); h != nil {
+			search.typeHandler = h.handler
+			search.typeParams = append(search.typeParams[:0], search.params...)
+			search.typeDepth = cursor
+		}
+	}
+
+	if defaultHandler != nil && cursor > search.defaultDepth {
+		search.defaultHandler = defaultHandler
+		search.defaultParams = append(search.defaultParams[:0], search.params...)
+		search.defaultDepth = cursor
+	}
+
+	if notFoundHandler != nil && cursor > search.notFoundDepth {
+		search.notFoundHandler = notFoundHandler
+		search.notFoundDepth = cursor
+	}
+	return subject, nil
+}
+
+// searchWildcardChild try the wildcard children by priority,
+// if a branch can't find handler, its param is removed and the next child is tried.
+func searchWildcardChild[N searchNode](children []N, delimiter string, subject string, cursor int, search *trieSearch, message *Message, dep any) (HandleFunc, error) {
+	if len(children) == 0 {
+		return nil, nil
+	}
+
+	segmentFinish := cursor
+	for segmentFinish < len(subject) && subject[segmentFinish] != delimiter[0] {
+		segmentFinish++
 	}
+	segment := unsafeSubString(subject, cursor, segmentFinish)
+
+	for _, child := range children {
+		w := child.wildcardOf()
+		if w.excludeDollar && cursor == 0 && strings.HasPrefix(subject, "$") {
+			continue
+		}
+
+		param := routeParam{key: w.word}
+		finish := segmentFinish
 
-	bytes := unsafe.Slice(unsafe.StringData(subject), len(subject))
-	value := bytes[wildcardStart:wildcardFinish]
-	str := unsafe.String(unsafe.SliceData(value), wildcardFinish-wildcardStart)
-	message.RouteParam.Set(wildcardParent.wildcardChildWord, str)
-	// message.RouteParam.Set(wildcardParent.wildcardChildWord, subject[wildcardStart:wildcardFinish])
+		switch w.kind {
+		case wildcardConstrained:
+			v, ok := w.constraint.convert(segment)
+			if !ok {
+				continue
+			}
+			param.value = v
+			param.segment = segment
+		case wildcardPlain:
+			param.segment = segment
+		case wildcardCatchAll:
+			param.segment = unsafeSubString(subject, cursor, len(subject))
+			finish = len(subject)
+		}
 
-	err := wildcardParent.wildcardChild.handleMessage(subject, wildcardFinish, message, dep)
-	if err != nil && errors.Is(err, ErrNotFoundSubject) {
-		if defaultHandler != nil {
-			return defaultHandler(message, dep)
+		if w.nonEmpty && finish == cursor {
+			continue
 		}
-		if notFoundHandler != nil {
-			return notFoundHandler(message, dep)
+
+		size := len(search.params)
+		search.params = append(search.params, param)
+
+		handler, err := child.search(subject, finish, search, message, dep)
+		if handler != nil || err != nil {
+			return handler, err
 		}
-		return ErrNotFoundSubject
+
+		search.params = search.params[:size]
 	}
-	return err
+	return nil, nil
+}
+
+// searchParentMatcher is used when the parent level of mqtt '#' is the end of subject.
+func searchParentMatcher[N searchNode](children []N, subject string, cursor int, search *trieSearch, message *Message, dep any) (HandleFunc, error) {
+	for _, child := range children {
+		w := child.wildcardOf()
+		if !w.matchParent {
+			continue
+		}
+
+		size := len(search.params)
+		search.params = append(search.params, routeParam{key: w.word})
+
+		handler, err := child.search(subject, cursor, search, message, dep)
+		if handler != nil || err != nil {
+			return handler, err
+		}
+
+		search.params = search.params[:size]
+	}
+	return nil, nil
+}
+
+func unsafeSubString(str string, start, finish int) string {
+	bytes := unsafe.Slice(unsafe.StringData(str), len(str))
+	value := bytes[start:finish]
+	return unsafe.String(unsafe.SliceData(value), finish-start)
 }
 
 // pair = [subject, function]
 func (node *trie) endpoint() (pairs [][2]string) {
-	pairs = make([][2]string, 0)
-	node._endpoint_(&pairs)
+	routes := node.routes()
+	pairs = make([][2]string, 0, len(routes))
+	for _, route := range routes {
+		pairs = append(pairs, [2]string{route.endpoint(), route.Handler})
+	}
+	return
+}
+
+func (node *trie) routes() Routes {
+	routes := make(Routes, 0)
+	node._routes_(&routes, "", nil, nil)
 
-	sort.SliceStable(pairs, func(i, j int) bool {
-		return pairs[i][0] < pairs[j][0]
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].endpoint() < routes[j].endpoint()
 	})
-	return
+	return routes
 }
 
-func (node *trie) _endpoint_(paris *[][2]string) {
+func (node *trie) _routes_(routes *Routes, groupPrefix string, paramNames []string, transforms []string) {
+	if node.isGroup {
+		groupPrefix = node.fullSubject
+	}
+	if node.wildcard != nil {
+		paramNames = append(paramNames[:len(paramNames):len(paramNames)], node.wildcard.word)
+	}
+	if node.transform != nil {
+		transforms = append(transforms[:len(transforms):len(transforms)], functionName(node.transform))
+	}
+
 	if node.handler != nil {
-		*paris = append(*paris, [2]string{node.fullSubject, node.handlerName})
+		*routes = append(*routes, RouteInfo{
+			Subject:     node.fullSubject,
+			Handler:     node.handlerName,
+			Name:        node.routeName,
+			ParamNames:  paramNames,
+			GroupPrefix: groupPrefix,
+			Middlewares: node.handlerChain,
+			Transforms:  transforms,
+		})
+	}
+	for _, c := range node.conditionalHandlers {
+		*routes = append(*routes, RouteInfo{
+			Subject:     node.fullSubject,
+			Handler:     c.handlerName,
+			Name:        node.routeName,
+			ParamNames:  paramNames,
+			Conditional: true,
+			GroupPrefix: groupPrefix,
+			Middlewares: c.handlerChain,
+			Transforms:  transforms,
+		})
 	}
+	node.typeHandlers.each(func(h *typeHandler) {
+		*routes = append(*routes, RouteInfo{
+			Subject:     node.fullSubject,
+			Handler:     h.handlerName,
+			ParamNames:  paramNames,
+			BodyType:    h.bodyType.String(),
+			GroupPrefix: groupPrefix,
+			Middlewares: h.handlerChain,
+			Transforms:  transforms,
+		})
+	})
 	if node.defaultHandler != nil {
-		*paris = append(*paris, [2]string{node.fullSubject + ".*", node.defaultHandlerName})
+		*routes = append(*routes, RouteInfo{
+			Subject:     node.fullSubject,
+			Handler:     node.defaultHandlerName,
+			ParamNames:  paramNames,
+			IsDefault:   true,
+			GroupPrefix: groupPrefix,
+			Middlewares: node.defaultHandlerChain,
+			Transforms:  transforms,
+		})
 	}
 
 	for _, next := range node.staticChild {
-		next._endpoint_(paris)
+		next._routes_(routes, groupPrefix, paramNames, transforms)
 	}
 
-	if node.wildcardChild == nil {
-		return
+	for _, next := range node.wildcardChild {
+		next._routes_(routes, groupPrefix, paramNames, transforms)
 	}
-	node.wildcardChild._endpoint_(paris)
 }
@@ -0,0 +1,187 @@
+package art
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// NewTypeRouter create a standalone router which routes on the dynamic type of Message.Body.
+//
+//	router := art.NewTypeRouter().
+//		HandlerForType(func(msg *art.Message, ev OrderCreated, dep any) error { ... }).
+//		HandlerForType(func(msg *art.Message, ev OrderCanceled, dep any) error { ... })
+//
+//	err := router.HandleMessage(message, dep)
+func NewTypeRouter() *TypeRouter {
+	return &TypeRouter{}
+}
+
+// TypeRouter is safe for concurrent use.
+type TypeRouter struct {
+	mu       sync.RWMutex
+	registry typeRegistry
+}
+
+// HandlerForType
+// fn must be a function with the signature func(message *Message, body T, dep any) error,
+// T is the dynamic type of Message.Body, it can also be an interface.
+// If fn is invalid or T has been registered, it will panic.
+func (router *TypeRouter) HandlerForType(fn any, mw ...Middleware) *TypeRouter {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+
+	h, err := newTypeHandler(fn, mw...)
+	if err != nil {
+		panic(err)
+	}
+	if !router.registry.add(h) {
+		panic(newRouteError(ErrDuplicatedRoute, "", "assign duplicated handler for type %v", h.bodyType))
+	}
+	return router
+}
+
+// HandleMessage return ErrNotFoundBodyType, if the type of Message.Body isn't registered.
+func (router *TypeRouter) HandleMessage(message *Message, dependency any) error {
+	router.mu.RLock()
+	h := router.registry.find(message.Body)
+	router.mu.RUnlock()
+
+	if h == nil {
+		return ErrorWrapWithMessage(ErrNotFoundBodyType, "body=%T", message.Body)
+	}
+	return h.handler(message, dependency)
+}
+
+// typeRegistry is keyed by reflect.Type,
+// the exact type is found first, then the interfaces are checked in registration order.
+type typeRegistry struct {
+	exact      map[reflect.Type]*typeHandler
+	interfaces []*typeHandler
+}
+
+type typeHandler struct {
+	bodyType               reflect.Type
+	handler                HandleFunc
+	handlerName            string
+	handlerMiddlewareNames []string
+	handlerChain           []string
+	rawHandler             HandleFunc // without path middlewares
+}
+
+func (registry *typeRegistry) isEmpty() bool {
+	return len(registry.exact) == 0 && len(registry.interfaces) == 0
+}
+
+// add return false, if the type has been registered.
+func (registry *typeRegistry) add(h *typeHandler) bool {
+	if registry.get(h.bodyType) != nil {
+		return false
+	}
+
+	if h.bodyType.Kind() == reflect.Interface {
+		registry.interfaces = append(registry.interfaces, h)
+		return true
+	}
+	if registry.exact == nil {
+		registry.exact = make(map[reflect.Type]*typeHandler)
+	}
+	registry.exact[h.bodyType] = h
+	return true
+}
+
+func (registry *typeRegistry) get(bodyType reflect.Type) *typeHandler {
+	if h, ok := registry.exact[bodyType]; ok {
+		return h
+	}
+	for _, h := range registry.interfaces {
+		if h.bodyType == bodyType {
+			return h
+		}
+	}
+	return nil
+}
+
+func (registry *typeRegistry) find(body any) *typeHandler {
+	if body == nil {
+		return nil
+	}
+
+	bodyType := reflect.TypeOf(body)
+	if h, ok := registry.exact[bodyType]; ok {
+		return h
+	}
+	for _, h := range registry.interfaces {
+		if bodyType.Implements(h.bodyType) {
+			return h
+		}
+	}
+	return nil
+}
+
+// each iterate handlers in a stable order: exact types sorted by name, then interfaces.
+func (registry *typeRegistry) each(fn func(h *typeHandler)) {
+	exact := make([]*typeHandler, 0, len(registry.exact))
+	for _, h := range registry.exact {
+		exact = append(exact, h)
+	}
+	sort.Slice(exact, func(i, j int) bool {
+		return exact[i].bodyType.String() < exact[j].bodyType.String()
+	})
+
+	for _, h := range exact {
+		fn(h)
+	}
+	for _, h := range registry.interfaces {
+		fn(h)
+	}
+}
+
+var (
+	messageType = reflect.TypeOf((*Message)(nil))
+	anyType     = reflect.TypeOf((*any)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+func newTypeHandler(fn any, mw ...Middleware) (*typeHandler, error) {
+	value := reflect.ValueOf(fn)
+	if !value.IsValid() {
+		return nil, fmt.Errorf("handler must be func(*Message, T, any) error")
+	}
+
+	fnType := value.Type()
+	if fnType.Kind() != reflect.Func ||
+		fnType.NumIn() != 3 || fnType.In(0) != messageType || fnType.In(2) != anyType ||
+		fnType.NumOut() != 1 || fnType.Out(0) != errorType {
+		return nil, fmt.Errorf("%v: handler must be func(*Message, T, any) error", fnType)
+	}
+	bodyType := fnType.In(1)
+
+	var handler HandleFunc = func(message *Message, dep any) error {
+		args := []reflect.Value{
+			reflect.ValueOf(message),
+			reflect.ValueOf(message.Body),
+			reflect.ValueOf(&dep).Elem(),
+		}
+		if message.Body == nil {
+			args[1] = reflect.Zero(bodyType)
+		}
+
+		out := value.Call(args)
+		err, _ := out[0].Interface().(error)
+		return err
+	}
+
+	h := &typeHandler{
+		bodyType:    bodyType,
+		handlerName: functionName(fn),
+	}
+	if mw != nil {
+		handler = Link(handler, mw...)
+		h.handlerMiddlewareNames = middlewareNames(mw)
+	}
+	h.handler = handler
+	h.rawHandler = handler
+	return h, nil
+}
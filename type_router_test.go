@@ -0,0 +1,81 @@
+package art
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type testOrderCreated struct {
+	Id string
+}
+
+type testOrderCanceled struct {
+	Id string
+}
+
+func (ev *testOrderCanceled) String() string {
+	return "canceled " + ev.Id
+}
+
+func TestTypeRouter_HandleMessage(t *testing.T) {
+	recorder := []string{}
+	router := NewTypeRouter().
+		HandlerForType(func(msg *Message, ev testOrderCreated, dep any) error {
+			recorder = append(recorder, fmt.Sprintf("created %v %v", ev.Id, dep))
+			return nil
+		}).
+		HandlerForType(func(msg *Message, ev fmt.Stringer, dep any) error {
+			recorder = append(recorder, ev.String())
+			return nil
+		})
+
+	expected := []string{
+		"created 1 dep",
+		"canceled 2",
+	}
+
+	messages := []*Message{
+		{Body: testOrderCreated{Id: "1"}},
+		{Body: &testOrderCanceled{Id: "2"}},
+	}
+
+	for _, message := range messages {
+		err := router.HandleMessage(message, "dep")
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if fmt.Sprint(recorder) != fmt.Sprint(expected) {
+		t.Errorf("unexpected output: got %v, want %v", recorder, expected)
+	}
+
+	err := router.HandleMessage(&Message{Body: testOrderCanceled{}}, nil)
+	if !errors.Is(err, ErrNotFoundBodyType) {
+		t.Errorf("unexpected error: got %v, want %v", err, ErrNotFoundBodyType)
+	}
+}
+
+func TestTypeRouter_HandlerForType_when_invalid_handler(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   any
+	}{
+		{name: "nil", fn: nil},
+		{name: "not func", fn: 1},
+		{name: "without dep", fn: func(msg *Message, ev testOrderCreated) error { return nil }},
+		{name: "without error", fn: func(msg *Message, ev testOrderCreated, dep any) {}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected panic")
+				}
+			}()
+			NewTypeRouter().HandlerForType(tt.fn)
+		})
+	}
+}